@@ -1,16 +1,69 @@
 package main
 
 import (
+	"api/src/authentication"
 	"api/src/config"
+	"api/src/database"
+	"api/src/lockout"
+	"api/src/mail"
+	"api/src/migrate"
+	"api/src/oidc"
+	"api/src/purge"
+	"api/src/repositories"
 	"api/src/router"
+	"api/src/security"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 )
 
 func main() {
 	config.Load()
-	r := router.Gerar()
+
+	db, err := database.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate.Run(context.Background(), db, os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := migrate.Check(context.Background(), db); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := database.OpenReplicas(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := authentication.LoadKeys(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := mail.Load(); err != nil {
+		log.Fatal(err)
+	}
+	lockout.Load()
+
+	if err := security.LoadBreachedPasswords(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := oidc.Load(); err != nil {
+		log.Fatal(err)
+	}
+
+	userStore, postStore := repositories.NewUserRepository(db), repositories.NewPostRepository(db)
+	purge.Start(userStore, postStore)
+
+	r := router.Gerar(db, userStore, postStore)
 
 	fmt.Printf("Listening at Port %d", config.Port)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", config.Port), r))
@@ -0,0 +1,11 @@
+// Package migrations embeds the numbered migrations of the database schema in the binary, on a directory per
+// database dialect. Each version has a [VERSION]_[NAME].up.sql file and a [VERSION]_[NAME].down.sql file that
+// undoes it, and every dialect has the same versions
+package migrations
+
+import "embed"
+
+// Files are the up and down migrations, under the mysql, postgres and sqlite directories
+//
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var Files embed.FS
@@ -1,20 +1,18 @@
-CREATE DATABASE IF NOT EXISTS socialmedia;
-USE socialmedia;
-
-DROP TABLE IF EXISTS posts;
-DROP TABLE IF EXISTS followers;
-DROP TABLE IF EXISTS users;
-
-CREATE TABLE users(
+CREATE TABLE IF NOT EXISTS users(
     id int auto_increment primary key,
     name varchar(50) not null,
     nick varchar(50) not null unique,
     email varchar(50) not null unique,
-    password varchar(100) not null,
+    email_verified_at datetime null default null,
+    password varchar(255) not null,
+    tokens_valid_after datetime null default null,
+    totp_secret varchar(64) null default null,
+    totp_enabled boolean not null default false,
+    totp_last_step bigint not null default 0,
     createdAt timestamp default current_timestamp()
 ) ENGINE=INNODB;
 
-CREATE TABLE followers(
+CREATE TABLE IF NOT EXISTS followers(
     user_id int not null,
     FOREIGN KEY(user_id)
     REFERENCES users(id)
@@ -28,7 +26,7 @@ CREATE TABLE followers(
     primary key(user_id, follower_id)
 ) ENGINE=INNODB;
 
-CREATE TABLE posts(
+CREATE TABLE IF NOT EXISTS posts(
     id int auto_increment primary key,
     title varchar(50) not null,
     content varchar(300) not null,
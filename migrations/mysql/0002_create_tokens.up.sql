@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens(
+    id int auto_increment primary key,
+
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    family_id varchar(64) not null,
+    token_hash char(64) not null unique,
+    expires_at datetime not null,
+    rotated_at datetime null default null,
+    revoked_at datetime null default null,
+    createdAt timestamp default current_timestamp(),
+
+    index(family_id)
+) ENGINE=INNODB;
+
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+    token_id varchar(64) primary key,
+
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    expires_at datetime not null,
+    createdAt timestamp default current_timestamp()
+) ENGINE=INNODB;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS recovery_codes(
+    id int auto_increment primary key,
+
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    code_hash char(64) not null unique,
+    used_at datetime null default null,
+    createdAt timestamp default current_timestamp()
+) ENGINE=INNODB;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS password_resets(
+    id int auto_increment primary key,
+
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    token_hash char(64) not null unique,
+    expires_at datetime not null,
+    used_at datetime null default null,
+    createdAt timestamp default current_timestamp()
+) ENGINE=INNODB;
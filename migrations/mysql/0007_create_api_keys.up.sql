@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS api_keys(
+    id int auto_increment primary key,
+
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    name varchar(50) not null,
+    prefix varchar(20) not null,
+    key_hash char(64) not null unique,
+    scopes varchar(255) not null,
+    expires_at datetime null default null,
+    last_used_at datetime null default null,
+    createdAt timestamp default current_timestamp()
+) ENGINE=INNODB;
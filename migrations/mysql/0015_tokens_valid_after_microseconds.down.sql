@@ -0,0 +1 @@
+ALTER TABLE users MODIFY tokens_valid_after datetime null default null;
@@ -0,0 +1 @@
+ALTER TABLE users MODIFY tokens_valid_after datetime(6) null default null;
@@ -14,4 +14,7 @@ insert into posts(title, content, author_id)
 values
 ("Post of user 1", "this is the post of user 1! Yay!", 1),
 ("Post of user 2", "this is the post of user 2! Yay!", 2),
-("Post of user 2", "this is the post of user 3! Yay!", 3);
\ No newline at end of file
+("Post of user 2", "this is the post of user 3! Yay!", 3);
+insert into user_roles(user_id, role)
+values
+(1, "admin");
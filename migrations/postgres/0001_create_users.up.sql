@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS users(
+    id serial primary key,
+    name varchar(50) not null,
+    nick varchar(50) not null unique,
+    email varchar(50) not null unique,
+    email_verified_at timestamptz null default null,
+    password varchar(255) not null,
+    tokens_valid_after timestamptz null default null,
+    totp_secret varchar(64) null default null,
+    totp_enabled boolean not null default false,
+    totp_last_step bigint not null default 0,
+    createdAt timestamptz default current_timestamp
+);
+
+CREATE TABLE IF NOT EXISTS followers(
+    user_id int not null,
+    FOREIGN KEY(user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    follower_id int not null,
+    FOREIGN KEY(follower_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    primary key(user_id, follower_id)
+);
+
+CREATE TABLE IF NOT EXISTS posts(
+    id serial primary key,
+    title varchar(50) not null,
+    content varchar(300) not null,
+
+    author_id int not null,
+    FOREIGN KEY (author_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    likes int default 0,
+    createdAt timestamptz default current_timestamp
+);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens(
+    id serial primary key,
+
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    family_id varchar(64) not null,
+    token_hash char(64) not null unique,
+    expires_at timestamptz not null,
+    rotated_at timestamptz null default null,
+    revoked_at timestamptz null default null,
+    createdAt timestamptz default current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id ON refresh_tokens(family_id);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+    token_id varchar(64) primary key,
+
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    expires_at timestamptz not null,
+    createdAt timestamptz default current_timestamp
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS recovery_codes(
+    id serial primary key,
+
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    code_hash char(64) not null unique,
+    used_at timestamptz null default null,
+    createdAt timestamptz default current_timestamp
+);
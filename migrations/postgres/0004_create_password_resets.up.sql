@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS password_resets(
+    id serial primary key,
+
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    token_hash char(64) not null unique,
+    expires_at timestamptz not null,
+    used_at timestamptz null default null,
+    createdAt timestamptz default current_timestamp
+);
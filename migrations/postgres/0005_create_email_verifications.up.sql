@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS email_verifications(
+    id serial primary key,
+
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    email varchar(50) not null,
+    token_hash char(64) not null unique,
+    expires_at timestamptz not null,
+    used_at timestamptz null default null,
+    createdAt timestamptz default current_timestamp
+);
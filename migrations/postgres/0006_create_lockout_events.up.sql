@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS lockout_events(
+    id serial primary key,
+    kind varchar(20) not null,
+    identifier varchar(255) not null,
+    failures int not null,
+    locked_until timestamptz not null,
+    createdAt timestamptz default current_timestamp
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS api_keys(
+    id serial primary key,
+
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    name varchar(50) not null,
+    prefix varchar(20) not null,
+    key_hash char(64) not null unique,
+    scopes varchar(255) not null,
+    expires_at timestamptz null default null,
+    last_used_at timestamptz null default null,
+    createdAt timestamptz default current_timestamp
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS sessions(
+    id varchar(32) primary key,
+
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    client_id varchar(32) not null default '',
+    scopes varchar(255) not null default '',
+    user_agent varchar(255) not null,
+    ip varchar(45) not null,
+    createdAt timestamptz default current_timestamp,
+    last_seen_at timestamptz not null,
+    revoked_at timestamptz null default null
+);
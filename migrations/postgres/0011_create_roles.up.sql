@@ -0,0 +1,48 @@
+CREATE TABLE IF NOT EXISTS roles(
+    name varchar(50) primary key,
+    createdAt timestamptz default current_timestamp
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions(
+    role varchar(50) not null,
+    FOREIGN KEY (role)
+    REFERENCES roles(name)
+    ON DELETE CASCADE,
+
+    permission varchar(50) not null,
+
+    primary key(role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles(
+    user_id int not null,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    role varchar(50) not null,
+    FOREIGN KEY (role)
+    REFERENCES roles(name)
+    ON DELETE CASCADE,
+
+    createdAt timestamptz default current_timestamp,
+
+    primary key(user_id, role)
+);
+
+insert into roles (name) values ('user'), ('moderator'), ('admin')
+on conflict do nothing;
+
+insert into role_permissions (role, permission) values
+('moderator', 'posts:update:any'),
+('moderator', 'posts:delete:any'),
+('admin', 'posts:update:any'),
+('admin', 'posts:delete:any'),
+('admin', 'users:update:any'),
+('admin', 'users:delete:any'),
+('admin', 'roles:manage'),
+('admin', 'lockouts:read'),
+('admin', 'database:read'),
+('admin', 'users:impersonate'),
+('admin', 'impersonations:read')
+on conflict do nothing;
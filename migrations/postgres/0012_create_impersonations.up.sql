@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS impersonations(
+    id serial primary key,
+
+    actor_id int not null,
+    target_id int not null,
+
+    reason varchar(255) not null,
+    read_only boolean not null,
+    token_id varchar(32) not null unique,
+    started_at timestamptz not null,
+    expires_at timestamptz not null,
+    ended_at timestamptz null default null
+);
+
+CREATE TABLE IF NOT EXISTS impersonated_requests(
+    id serial primary key,
+
+    impersonation_id int not null,
+    FOREIGN KEY (impersonation_id)
+    REFERENCES impersonations(id),
+
+    method varchar(10) not null,
+    path varchar(255) not null,
+    status int not null,
+    createdAt timestamptz not null
+);
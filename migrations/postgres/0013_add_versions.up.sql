@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN version int not null default 1;
+
+ALTER TABLE posts ADD COLUMN version int not null default 1;
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN deleted_at timestamptz null default null;
+
+ALTER TABLE posts ADD COLUMN deleted_at timestamptz null default null;
@@ -0,0 +1 @@
+-- tokens_valid_after already keeps the microseconds, only MySQL's datetime drops them
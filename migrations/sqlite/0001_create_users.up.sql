@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS users(
+    id integer primary key autoincrement,
+    name varchar(50) not null,
+    nick varchar(50) not null unique,
+    email varchar(50) not null unique,
+    email_verified_at datetime null default null,
+    password varchar(255) not null,
+    tokens_valid_after datetime null default null,
+    totp_secret varchar(64) null default null,
+    totp_enabled boolean not null default false,
+    totp_last_step bigint not null default 0,
+    createdAt timestamp default current_timestamp
+);
+
+CREATE TABLE IF NOT EXISTS followers(
+    user_id int not null REFERENCES users(id) ON DELETE CASCADE,
+
+    follower_id int not null REFERENCES users(id) ON DELETE CASCADE,
+
+    primary key(user_id, follower_id)
+);
+
+CREATE TABLE IF NOT EXISTS posts(
+    id integer primary key autoincrement,
+    title varchar(50) not null,
+    content varchar(300) not null,
+
+    author_id int not null REFERENCES users(id) ON DELETE CASCADE,
+
+    likes int default 0,
+    createdAt timestamp default current_timestamp
+);
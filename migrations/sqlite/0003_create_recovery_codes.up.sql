@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS recovery_codes(
+    id integer primary key autoincrement,
+
+    user_id int not null REFERENCES users(id) ON DELETE CASCADE,
+
+    code_hash char(64) not null unique,
+    used_at datetime null default null,
+    createdAt timestamp default current_timestamp
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS email_verifications(
+    id integer primary key autoincrement,
+
+    user_id int not null REFERENCES users(id) ON DELETE CASCADE,
+
+    email varchar(50) not null,
+    token_hash char(64) not null unique,
+    expires_at datetime not null,
+    used_at datetime null default null,
+    createdAt timestamp default current_timestamp
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS lockout_events(
+    id integer primary key autoincrement,
+    kind varchar(20) not null,
+    identifier varchar(255) not null,
+    failures int not null,
+    locked_until datetime not null,
+    createdAt timestamp default current_timestamp
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS api_keys(
+    id integer primary key autoincrement,
+
+    user_id int not null REFERENCES users(id) ON DELETE CASCADE,
+
+    name varchar(50) not null,
+    prefix varchar(20) not null,
+    key_hash char(64) not null unique,
+    scopes varchar(255) not null,
+    expires_at datetime null default null,
+    last_used_at datetime null default null,
+    createdAt timestamp default current_timestamp
+);
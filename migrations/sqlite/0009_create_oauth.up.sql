@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS oauth_clients(
+    id integer primary key autoincrement,
+    client_id varchar(32) not null unique,
+
+    user_id int not null REFERENCES users(id) ON DELETE CASCADE,
+
+    name varchar(50) not null,
+    redirect_uris varchar(1000) not null,
+    confidential boolean not null default false,
+    secret_hash char(64) not null default '',
+    createdAt timestamp default current_timestamp
+);
+
+CREATE TABLE IF NOT EXISTS oauth_authorization_codes(
+    id integer primary key autoincrement,
+    code_hash char(64) not null unique,
+
+    client_id varchar(32) not null REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+
+    user_id int not null REFERENCES users(id) ON DELETE CASCADE,
+
+    redirect_uri varchar(255) not null,
+    scopes varchar(255) not null,
+    code_challenge varchar(128) not null,
+    session_id varchar(32) null default null,
+    expires_at datetime not null,
+    used_at datetime null default null,
+    createdAt timestamp default current_timestamp
+);
+
+CREATE TABLE IF NOT EXISTS oauth_grants(
+    user_id int not null REFERENCES users(id) ON DELETE CASCADE,
+
+    client_id varchar(32) not null REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+
+    scopes varchar(255) not null,
+    createdAt timestamp default current_timestamp,
+
+    primary key(user_id, client_id)
+);
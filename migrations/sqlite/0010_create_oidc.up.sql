@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS oidc_states(
+    state_hash char(64) primary key,
+    provider varchar(50) not null,
+    nonce varchar(64) not null,
+    code_verifier varchar(128) not null,
+    expires_at datetime not null
+);
+
+CREATE TABLE IF NOT EXISTS user_identities(
+    user_id int not null REFERENCES users(id) ON DELETE CASCADE,
+
+    provider varchar(50) not null,
+    subject varchar(255) not null,
+    createdAt timestamp default current_timestamp,
+
+    primary key(provider, subject)
+);
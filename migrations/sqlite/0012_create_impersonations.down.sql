@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS impersonated_requests;
+DROP TABLE IF EXISTS impersonations;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS impersonations(
+    id integer primary key autoincrement,
+
+    actor_id int not null,
+    target_id int not null,
+
+    reason varchar(255) not null,
+    read_only boolean not null,
+    token_id varchar(32) not null unique,
+    started_at datetime not null,
+    expires_at datetime not null,
+    ended_at datetime null default null
+);
+
+CREATE TABLE IF NOT EXISTS impersonated_requests(
+    id integer primary key autoincrement,
+
+    impersonation_id int not null REFERENCES impersonations(id),
+
+    method varchar(10) not null,
+    path varchar(255) not null,
+    status int not null,
+    createdAt datetime not null
+);
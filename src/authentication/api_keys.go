@@ -0,0 +1,57 @@
+package authentication
+
+import (
+	"api/src/repositories"
+	"api/src/security"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// APIKeyPrefix starts every API key, telling them apart from the JWTs on the Authorization header
+const APIKeyPrefix = "smk_"
+
+// GenerateAPIKey creates a new random API key, returning it with the prefix shown to identify it
+func GenerateAPIKey() (key, prefix string, err error) {
+	secret, err := security.GenerateToken(32)
+	if err != nil {
+		return
+	}
+
+	key = APIKeyPrefix + secret
+	prefix = key[:len(APIKeyPrefix)+8]
+	return
+}
+
+// validateAPIKey finds the API key on the database, returning claims limited to its scopes
+func validateAPIKey(ctx context.Context, db *sql.DB, key string) (*Claims, error) {
+	apiKeysRepository := repositories.NewAPIKeysRepository(db)
+	apiKey, err := apiKeysRepository.SearchByHash(ctx, security.HashToken(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if apiKey.ID == 0 || apiKey.Expired() {
+		return nil, errors.New("invalid API key")
+	}
+
+	// The last use is precise to the minute, sparing a write on every request
+	if apiKey.LastUsedAt == nil || time.Since(*apiKey.LastUsedAt) > time.Minute {
+		if err = apiKeysRepository.Touch(ctx, apiKey.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Claims{
+		UserID: apiKey.UserID,
+		Scopes: apiKey.Scopes,
+		StandardClaims: jwt.StandardClaims{
+			Id:       fmt.Sprintf("apikey:%d", apiKey.ID),
+			IssuedAt: apiKey.CreatedAt.Unix(),
+		},
+	}, nil
+}
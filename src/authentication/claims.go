@@ -0,0 +1,71 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Claims are the informations carried by the tokens issued by the API. The token ID (jti) and
+// the issued at (iat) come from the standard claims, and iat_us repeats the issued at in microseconds
+// since iat only has whole seconds
+type Claims struct {
+	UserID        uint64   `json:"userID"`
+	SessionID     string   `json:"sid,omitempty"`
+	Roles         []string `json:"roles,omitempty"`
+	Permissions   []string `json:"perms,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+	ClientID      string   `json:"client_id,omitempty"`
+	Actor         *Actor   `json:"act,omitempty"`
+	ReadOnly      bool     `json:"read_only,omitempty"`
+	Purpose       string   `json:"purpose,omitempty"`
+	IssuedAtMicro int64    `json:"iat_us,omitempty"`
+	jwt.StandardClaims
+}
+
+// Actor is who is really acting when an admin impersonates the user of the token (RFC 8693)
+type Actor struct {
+	UserID uint64 `json:"userID"`
+}
+
+// issuedAt is when the token was issued, in whole seconds for the tokens issued before IssuedAtMicro existed
+func (claims *Claims) issuedAt() time.Time {
+	if claims.IssuedAtMicro != 0 {
+		return time.UnixMicro(claims.IssuedAtMicro)
+	}
+
+	return time.Unix(claims.IssuedAt, 0)
+}
+
+// PurposeMFA marks a token that only proves the password step of a login, waiting for the second factor
+const PurposeMFA = "mfa"
+
+type claimsContextKey struct{}
+
+// WithClaims returns a copy of the request carrying the claims of the authenticated principal
+func WithClaims(r *http.Request, claims *Claims) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, claims))
+}
+
+// ClaimsFromRequest gets the claims stored on the request by the authentication middleware
+func ClaimsFromRequest(r *http.Request) (*Claims, error) {
+	claims, ok := r.Context().Value(claimsContextKey{}).(*Claims)
+	if !ok || claims == nil {
+		return nil, errors.New("the request is not authenticated")
+	}
+
+	return claims, nil
+}
+
+// UserIDFromRequest gets the ID of the authenticated user stored on the request by the authentication middleware
+func UserIDFromRequest(r *http.Request) (uint64, error) {
+	claims, err := ClaimsFromRequest(r)
+	if err != nil {
+		return 0, err
+	}
+
+	return claims.UserID, nil
+}
@@ -0,0 +1,40 @@
+package authentication
+
+import (
+	"api/src/repositories"
+	"context"
+	"database/sql"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// EndImpersonation revokes the token of an impersonation and records that it ended
+func EndImpersonation(ctx context.Context, db *sql.DB, tokenID string) (err error) {
+	impersonationsRepository := repositories.NewImpersonationsRepository(db)
+	impersonation, err := impersonationsRepository.SearchByTokenID(ctx, tokenID)
+	if err != nil || impersonation.ID == 0 {
+		return
+	}
+
+	if err = RevokeToken(ctx, db, &Claims{
+		UserID: impersonation.TargetID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        impersonation.TokenID,
+			ExpiresAt: impersonation.ExpiresAt.Unix(),
+		},
+	}); err != nil {
+		return
+	}
+
+	return impersonationsRepository.End(ctx, impersonation.ID)
+}
+
+// RecordImpersonatedRequest adds a request made with an impersonation token to its audit trail
+func RecordImpersonatedRequest(ctx context.Context, db *sql.DB, claims *Claims, method, path string, status int) (err error) {
+	if len(path) > 255 {
+		path = path[:255]
+	}
+
+	impersonationsRepository := repositories.NewImpersonationsRepository(db)
+	return impersonationsRepository.CreateRequest(ctx, claims.Id, method, path, status)
+}
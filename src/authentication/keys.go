@@ -0,0 +1,201 @@
+package authentication
+
+import (
+	"api/src/config"
+	"api/src/models"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// signingKey is an asymmetric key that signs or verifies tokens, identified by the kid header
+type signingKey struct {
+	id         string
+	method     jwt.SigningMethod
+	privateKey crypto.Signer
+	publicKey  crypto.PublicKey
+	retiredAt  *time.Time
+}
+
+// keyRing holds every configured key and the one that signs new tokens
+type keyRing struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+var keys *keyRing
+
+// LoadKeys reads the signing keys from the configuration. Without any of them, tokens keep being signed with the secret key
+func LoadKeys() error {
+	if len(config.JWTSigningKeys) == 0 {
+		keys = nil
+		return nil
+	}
+
+	ring := &keyRing{keys: map[string]*signingKey{}}
+
+	for keyID, path := range config.JWTSigningKeys {
+		key, err := readSigningKey(keyID, path)
+		if err != nil {
+			return err
+		}
+
+		if retiredAt, retired := config.JWTRetiredKeys[keyID]; retired {
+			key.retiredAt = &retiredAt
+		}
+
+		ring.keys[keyID] = key
+	}
+
+	active, found := ring.keys[config.JWTActiveKey]
+	if !found {
+		return fmt.Errorf("the active key %q is not one of the signing keys", config.JWTActiveKey)
+	}
+
+	if active.retiredAt != nil {
+		return fmt.Errorf("the active key %q can't be retired", config.JWTActiveKey)
+	}
+	ring.active = active
+
+	keys = ring
+	return nil
+}
+
+// PublicKeys returns the keys that can still verify tokens, so other services can verify them without the API
+func PublicKeys() (keySet models.JSONWebKeySet) {
+	keySet.Keys = []models.JSONWebKey{}
+	if keys == nil {
+		return
+	}
+
+	for _, key := range keys.keys {
+		if key.expired() {
+			continue
+		}
+
+		keySet.Keys = append(keySet.Keys, key.jsonWebKey())
+	}
+
+	sort.Slice(keySet.Keys, func(i, j int) bool {
+		return keySet.Keys[i].KeyID < keySet.Keys[j].KeyID
+	})
+
+	return
+}
+
+// signToken signs the claims with the active key, or with the secret key when there are no asymmetric keys
+func signToken(claims jwt.Claims) (string, error) {
+	if keys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(config.SecretKey)) // SECRET
+	}
+
+	token := jwt.NewWithClaims(keys.active.method, claims)
+	token.Header["kid"] = keys.active.id
+	return token.SignedString(keys.active.privateKey)
+}
+
+func getVerificationKey(token *jwt.Token) (interface{}, error) {
+	if keys == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signed method! %v", token.Header["alg"])
+		}
+
+		return []byte(config.SecretKey), nil
+	}
+
+	keyID, _ := token.Header["kid"].(string)
+	key, found := keys.keys[keyID]
+	if !found {
+		return nil, fmt.Errorf("unknown signing key! %v", token.Header["kid"])
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signed method! %v", token.Header["alg"])
+	}
+
+	if key.expired() {
+		return nil, fmt.Errorf("the signing key %s was retired", keyID)
+	}
+
+	return key.publicKey, nil
+}
+
+// expired reports if the key was retired longer than the grace period ago
+func (key *signingKey) expired() bool {
+	return key.retiredAt != nil && time.Now().After(key.retiredAt.Add(config.JWTKeyGracePeriod))
+}
+
+func (key *signingKey) jsonWebKey() models.JSONWebKey {
+	jsonWebKey := models.JSONWebKey{
+		KeyID:     key.id,
+		Use:       "sig",
+		Algorithm: key.method.Alg(),
+	}
+
+	switch publicKey := key.publicKey.(type) {
+	case *rsa.PublicKey:
+		jsonWebKey.KeyType = "RSA"
+		jsonWebKey.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jsonWebKey.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jsonWebKey.KeyType = "OKP"
+		jsonWebKey.Curve = "Ed25519"
+		jsonWebKey.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	}
+
+	return jsonWebKey
+}
+
+// readSigningKey reads a PEM encoded RSA (PKCS #1 or #8) or Ed25519 (PKCS #8) private key
+func readSigningKey(keyID, path string) (*signingKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the key %s: %w", keyID, err)
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("the key %s is not PEM encoded", keyID)
+	}
+
+	var privateKey interface{}
+	if block.Type == "RSA PRIVATE KEY" {
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing the key %s: %w", keyID, err)
+	}
+
+	switch privateKey := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return &signingKey{
+			id:         keyID,
+			method:     jwt.SigningMethodRS256,
+			privateKey: privateKey,
+			publicKey:  &privateKey.PublicKey,
+		}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{
+			id:         keyID,
+			method:     SigningMethodEdDSA,
+			privateKey: privateKey,
+			publicKey:  privateKey.Public(),
+		}, nil
+	}
+
+	return nil, errors.New("only RSA and Ed25519 keys are supported, check the key " + keyID)
+}
@@ -0,0 +1,36 @@
+package authentication
+
+import (
+	"api/src/repositories"
+	"api/src/security"
+	"context"
+	"database/sql"
+)
+
+// RevokeClientToken revokes an access or refresh token issued to the OAuth client, ending its session.
+// Tokens that are invalid or that belong to other clients are ignored, as the revocation specification asks
+func RevokeClientToken(ctx context.Context, db *sql.DB, token, clientID string) (err error) {
+	refreshTokensRepository := repositories.NewRefreshTokensRepository(db)
+	refreshToken, err := refreshTokensRepository.SearchByHash(ctx, security.HashToken(token))
+	if err != nil {
+		return
+	}
+
+	if refreshToken.ID != 0 {
+		sessionsRepository := repositories.NewSessionsRepository(db)
+		session, err := sessionsRepository.SearchByID(ctx, refreshToken.FamilyID)
+		if err != nil || session.ClientID != clientID {
+			return err
+		}
+
+		_, err = revokeSession(ctx, db, session.UserID, session.ID)
+		return err
+	}
+
+	claims, parseErr := parseToken(token)
+	if parseErr != nil || claims.ClientID != clientID {
+		return
+	}
+
+	return RevokeToken(ctx, db, claims)
+}
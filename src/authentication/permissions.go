@@ -0,0 +1,61 @@
+package authentication
+
+const (
+	// RoleUser is the role every user has, even without being granted it
+	RoleUser = "user"
+
+	// RoleModerator can moderate the posts of every user
+	RoleModerator = "moderator"
+
+	// RoleAdmin can manage every user and their roles
+	RoleAdmin = "admin"
+)
+
+const (
+	// PermissionUsersUpdateAny allows updating the profile of any user
+	PermissionUsersUpdateAny = "users:update:any"
+
+	// PermissionUsersDeleteAny allows deleting any user
+	PermissionUsersDeleteAny = "users:delete:any"
+
+	// PermissionPostsUpdateAny allows updating the posts of any user
+	PermissionPostsUpdateAny = "posts:update:any"
+
+	// PermissionPostsDeleteAny allows deleting the posts of any user
+	PermissionPostsDeleteAny = "posts:delete:any"
+
+	// PermissionRolesManage allows granting and revoking the roles of users
+	PermissionRolesManage = "roles:manage"
+
+	// PermissionLockoutsRead allows reading the login lockouts
+	PermissionLockoutsRead = "lockouts:read"
+
+	// PermissionDatabaseRead allows reading the statistics of the pool of connections to the database
+	PermissionDatabaseRead = "database:read"
+
+	// PermissionUsersImpersonate allows acting as another user, to reproduce the problems they report
+	PermissionUsersImpersonate = "users:impersonate"
+
+	// PermissionImpersonationsRead allows reading the audit trail of the impersonations
+	PermissionImpersonationsRead = "impersonations:read"
+)
+
+// Can reports if the claims carry every one of the permissions. Only tokens from an interactive login carry
+// permissions, the ones of their user's roles when they were issued
+func (claims *Claims) Can(permissions ...string) bool {
+	for _, permission := range permissions {
+		granted := false
+		for _, grantedPermission := range claims.Permissions {
+			if grantedPermission == permission {
+				granted = true
+				break
+			}
+		}
+
+		if !granted {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,345 @@
+package authentication
+
+import (
+	"api/src/config"
+	"api/src/database"
+	"api/src/repositories"
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// revocationCache keeps what the database answered about tokens, sessions and users, so most
+// authenticated requests don't need to reach it to know if a token was revoked
+type revocationCache struct {
+	mutex     sync.Mutex
+	tokens    map[string]cachedToken
+	sessions  map[string]cachedSession
+	users     map[uint64]cachedUser
+	lastSweep time.Time
+}
+
+type cachedToken struct {
+	revoked bool
+	until   time.Time
+}
+
+type cachedSession struct {
+	ended bool
+	until time.Time
+}
+
+type cachedUser struct {
+	exists           bool
+	tokensValidAfter *time.Time
+	until            time.Time
+}
+
+var revocations = &revocationCache{
+	tokens:   map[string]cachedToken{},
+	sessions: map[string]cachedSession{},
+	users:    map[uint64]cachedUser{},
+}
+
+// RevokeToken revokes an access token and ends the session it belongs to
+func RevokeToken(ctx context.Context, db *sql.DB, claims *Claims) (err error) {
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+
+	revokedTokensRepository := repositories.NewRevokedTokensRepository(db)
+	if err = revokedTokensRepository.Create(ctx, claims.Id, claims.UserID, expiresAt); err != nil {
+		return
+	}
+
+	if err = revokedTokensRepository.DeleteExpired(ctx); err != nil {
+		return
+	}
+
+	if claims.SessionID != "" {
+		if _, err = revokeSession(ctx, db, claims.UserID, claims.SessionID); err != nil {
+			return
+		}
+	}
+
+	revocations.setToken(claims.Id, cachedToken{revoked: true, until: expiresAt})
+	return
+}
+
+// RevokeSession ends a session of the user, so its access and refresh tokens stop working,
+// reporting false if the user had no active session with this ID
+func RevokeSession(ctx context.Context, db *sql.DB, userID uint64, sessionID string) (revoked bool, err error) {
+	return revokeSession(ctx, db, userID, sessionID)
+}
+
+// RevokeClientSessions ends the sessions of an OAuth client. An userID of 0 ends the sessions of every user
+func RevokeClientSessions(ctx context.Context, db *sql.DB, clientID string, userID uint64) (err error) {
+	sessionsRepository := repositories.NewSessionsRepository(db)
+	sessions, err := sessionsRepository.SearchActiveByClient(ctx, clientID)
+	if err != nil {
+		return
+	}
+
+	for _, session := range sessions {
+		if userID != 0 && session.UserID != userID {
+			continue
+		}
+
+		if _, err = revokeSession(ctx, db, session.UserID, session.ID); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func revokeSession(ctx context.Context, db *sql.DB, userID uint64, sessionID string) (revoked bool, err error) {
+	sessionsRepository := repositories.NewSessionsRepository(db)
+	if revoked, err = sessionsRepository.Revoke(ctx, userID, sessionID); err != nil {
+		return
+	}
+
+	refreshTokensRepository := repositories.NewRefreshTokensRepository(db)
+	if err = refreshTokensRepository.RevokeFamily(ctx, sessionID); err != nil {
+		return
+	}
+
+	revocations.setSession(sessionID, cachedSession{ended: true, until: time.Now().Add(config.RevocationCacheDuration)})
+	return
+}
+
+// RevokeUserTokens invalidates every token issued to the user until now
+func RevokeUserTokens(ctx context.Context, db *sql.DB, userID uint64) (err error) {
+	return RevokeUserTokensWith(ctx, db, userID, nil)
+}
+
+// RevokeUserTokensWith invalidates every token issued to the user until now on the same transaction as the writes
+// of work, so the tokens are only revoked when work succeeds, and work is undone when they can't be revoked
+func RevokeUserTokensWith(ctx context.Context, db *sql.DB, userID uint64, work func(tx *sql.Tx) error) (err error) {
+	var tokensValidAfter time.Time
+	if err = database.Transaction(ctx, db, func(tx *sql.Tx) (err error) {
+		if work != nil {
+			if err = work(tx); err != nil {
+				return
+			}
+		}
+
+		userRepository := repositories.NewUserRepository(tx)
+		if tokensValidAfter, err = userRepository.RevokeTokens(ctx, userID); err != nil {
+			return
+		}
+
+		refreshTokensRepository := repositories.NewRefreshTokensRepository(tx)
+		if err = refreshTokensRepository.RevokeAllFromUser(ctx, userID); err != nil {
+			return
+		}
+
+		sessionsRepository := repositories.NewSessionsRepository(tx)
+		return sessionsRepository.RevokeAllFromUser(ctx, userID)
+	}); err != nil {
+		return
+	}
+
+	revocations.setUser(userID, cachedUser{
+		exists:           true,
+		tokensValidAfter: &tokensValidAfter,
+		until:            time.Now().Add(config.RevocationCacheDuration),
+	})
+	return
+}
+
+// InvalidateAccessTokens invalidates the access tokens issued to the user until now, keeping its sessions.
+// The next access tokens, issued on the refresh, carry the current roles of the user
+func InvalidateAccessTokens(ctx context.Context, db *sql.DB, userID uint64) (err error) {
+	userRepository := repositories.NewUserRepository(db)
+	tokensValidAfter, err := userRepository.RevokeTokens(ctx, userID)
+	if err != nil {
+		return
+	}
+
+	revocations.setUser(userID, cachedUser{
+		exists:           true,
+		tokensValidAfter: &tokensValidAfter,
+		until:            time.Now().Add(config.RevocationCacheDuration),
+	})
+	return
+}
+
+// checkRevocation fails if the token was revoked, if its session has ended, if its user (or the admin
+// impersonating it) doesn't exist anymore or if it was issued before the user asked to revoke all of its tokens
+func checkRevocation(ctx context.Context, db *sql.DB, claims *Claims) (err error) {
+	if claims.Id == "" {
+		return errors.New("the token has no identifier")
+	}
+
+	token, err := revocations.token(ctx, db, claims.Id)
+	if err != nil {
+		return
+	}
+
+	if token.revoked {
+		return errors.New("the token has been revoked")
+	}
+
+	if claims.SessionID != "" {
+		session, err := revocations.session(ctx, db, claims.SessionID)
+		if err != nil {
+			return err
+		}
+
+		if session.ended {
+			return errors.New("the session of the token has ended")
+		}
+	}
+
+	user, err := revocations.user(ctx, db, claims.UserID)
+	if err != nil {
+		return
+	}
+
+	if !user.exists {
+		return errors.New("the token's user doesn't exist anymore")
+	}
+
+	if user.tokensValidAfter != nil && !claims.issuedAt().After(*user.tokensValidAfter) {
+		return errors.New("the token has been revoked")
+	}
+
+	// An impersonation can't outlive the tokens of the admin behind it
+	if claims.Actor != nil {
+		actor, err := revocations.user(ctx, db, claims.Actor.UserID)
+		if err != nil {
+			return err
+		}
+
+		if !actor.exists || actor.tokensValidAfter != nil && !claims.issuedAt().After(*actor.tokensValidAfter) {
+			return errors.New("the impersonation has been revoked")
+		}
+	}
+
+	return
+}
+
+func (cache *revocationCache) token(ctx context.Context, db *sql.DB, tokenID string) (token cachedToken, err error) {
+	cache.mutex.Lock()
+	token, found := cache.tokens[tokenID]
+	cache.mutex.Unlock()
+
+	if found && time.Now().Before(token.until) {
+		return
+	}
+
+	revokedTokensRepository := repositories.NewRevokedTokensRepository(db)
+	revoked, err := revokedTokensRepository.IsRevoked(ctx, tokenID)
+	if err != nil {
+		return
+	}
+
+	token = cachedToken{revoked: revoked, until: time.Now().Add(config.RevocationCacheDuration)}
+	cache.setToken(tokenID, token)
+	return
+}
+
+// session also records when the session was last seen, which is precise to the minute since it's only
+// reached when the cache misses
+func (cache *revocationCache) session(ctx context.Context, db *sql.DB, sessionID string) (session cachedSession, err error) {
+	cache.mutex.Lock()
+	session, found := cache.sessions[sessionID]
+	cache.mutex.Unlock()
+
+	if found && time.Now().Before(session.until) {
+		return
+	}
+
+	sessionsRepository := repositories.NewSessionsRepository(db)
+	savedSession, err := sessionsRepository.SearchByID(ctx, sessionID)
+	if err != nil {
+		return
+	}
+
+	ended := savedSession.ID == "" || savedSession.RevokedAt != nil
+	if !ended && time.Since(savedSession.LastSeenAt) > time.Minute {
+		if err = sessionsRepository.Touch(ctx, sessionID); err != nil {
+			return
+		}
+	}
+
+	session = cachedSession{ended: ended, until: time.Now().Add(config.RevocationCacheDuration)}
+	cache.setSession(sessionID, session)
+	return
+}
+
+func (cache *revocationCache) user(ctx context.Context, db *sql.DB, userID uint64) (user cachedUser, err error) {
+	cache.mutex.Lock()
+	user, found := cache.users[userID]
+	cache.mutex.Unlock()
+
+	if found && time.Now().Before(user.until) {
+		return
+	}
+
+	userRepository := repositories.NewUserRepository(db)
+	exists, tokensValidAfter, err := userRepository.SearchTokensValidAfter(ctx, userID)
+	if err != nil {
+		return
+	}
+
+	user = cachedUser{
+		exists:           exists,
+		tokensValidAfter: tokensValidAfter,
+		until:            time.Now().Add(config.RevocationCacheDuration),
+	}
+	cache.setUser(userID, user)
+	return
+}
+
+func (cache *revocationCache) setToken(tokenID string, token cachedToken) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.tokens[tokenID] = token
+	cache.sweep()
+}
+
+func (cache *revocationCache) setSession(sessionID string, session cachedSession) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.sessions[sessionID] = session
+	cache.sweep()
+}
+
+func (cache *revocationCache) setUser(userID uint64, user cachedUser) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.users[userID] = user
+	cache.sweep()
+}
+
+// sweep drops the stale entries from time to time, so the cache doesn't grow forever. Must hold the mutex
+func (cache *revocationCache) sweep() {
+	now := time.Now()
+	if now.Sub(cache.lastSweep) < config.RevocationCacheDuration {
+		return
+	}
+	cache.lastSweep = now
+
+	for tokenID, token := range cache.tokens {
+		if now.After(token.until) {
+			delete(cache.tokens, tokenID)
+		}
+	}
+
+	for sessionID, session := range cache.sessions {
+		if now.After(session.until) {
+			delete(cache.sessions, sessionID)
+		}
+	}
+
+	for userID, user := range cache.users {
+		if now.After(user.until) {
+			delete(cache.users, userID)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package authentication
+
+const (
+	// ScopeUsersRead allows reading users, their followers and who they follow
+	ScopeUsersRead = "users:read"
+
+	// ScopeUsersWrite allows updating the profile and following or unfollowing users
+	ScopeUsersWrite = "users:write"
+
+	// ScopePostsRead allows reading posts
+	ScopePostsRead = "posts:read"
+
+	// ScopePostsWrite allows creating, updating, deleting and liking posts
+	ScopePostsWrite = "posts:write"
+)
+
+// Scopes are all the scopes that can be granted to API keys and OAuth clients
+var Scopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopePostsRead, ScopePostsWrite}
+
+// ValidScope reports if the scope exists
+func ValidScope(scope string) bool {
+	for _, existingScope := range Scopes {
+		if scope == existingScope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Restricted reports if the claims are limited to their scopes. Tokens from an interactive login carry no scopes
+// and can do anything the user can, while API keys and OAuth tokens can only reach the routes that require one of their scopes
+func (claims *Claims) Restricted() bool {
+	return len(claims.Scopes) > 0
+}
+
+// HasScopes reports if the claims were granted every one of the scopes
+func (claims *Claims) HasScopes(scopes ...string) bool {
+	if !claims.Restricted() {
+		return true
+	}
+
+	for _, scope := range scopes {
+		granted := false
+		for _, grantedScope := range claims.Scopes {
+			if grantedScope == scope {
+				granted = true
+				break
+			}
+		}
+
+		if !granted {
+			return false
+		}
+	}
+
+	return true
+}
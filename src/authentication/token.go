@@ -2,54 +2,132 @@ package authentication
 
 import (
 	"api/src/config"
+	"api/src/security"
+	"context"
+	"database/sql"
 	"errors"
-	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 )
 
-// CreateToken based o
-func CreateToken(userID uint64) (string, error) {
-	permissions := jwt.MapClaims{}
-	permissions["authorized"] = true
-	permissions["exp"] = time.Now().Add(time.Hour * 6).Unix()
-	permissions["userID"] = userID
+// CreateToken creates a short lived access token for the user, bound to the login (refresh token family) it came from
+// and carrying the roles of the user with their permissions
+func CreateToken(userID uint64, sessionID string, roles, permissions []string) (string, error) {
+	return createAccessToken(&Claims{UserID: userID, SessionID: sessionID, Roles: roles, Permissions: permissions}, config.AccessTokenDuration)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, permissions)
-	return token.SignedString([]byte(config.SecretKey)) // SECRET
+// CreateClientToken creates a short lived access token for an OAuth client, limited to the scopes the user granted
+func CreateClientToken(userID uint64, sessionID, clientID string, scopes []string) (string, error) {
+	return createAccessToken(&Claims{UserID: userID, SessionID: sessionID, ClientID: clientID, Scopes: scopes}, config.AccessTokenDuration)
 }
 
-// ValidateToken verify if the user is authenticated
-func ValidateToken(r *http.Request) (err error) {
-	tokenString := extractToken(r)
+// CreateImpersonationToken creates a token for an admin (the actor) to act as the target user, without its roles
+// and blocked from writing unless allowed. It returns the claims too, so the impersonation can be recorded
+func CreateImpersonationToken(targetID, actorID uint64, readOnly bool) (string, *Claims, error) {
+	claims := &Claims{UserID: targetID, Actor: &Actor{UserID: actorID}, ReadOnly: readOnly}
 
-	_, err = jwt.Parse(tokenString, getVerificationKey)
-	return
+	token, err := createAccessToken(claims, config.ImpersonationDuration)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, claims, nil
+}
+
+func createAccessToken(claims *Claims, duration time.Duration) (string, error) {
+	tokenID, err := security.GenerateToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.Id = tokenID
+	claims.IssuedAt = now.Unix()
+	claims.IssuedAtMicro = now.UnixMicro()
+	claims.ExpiresAt = now.Add(duration).Unix()
+
+	return signToken(claims)
 }
 
-func ExtractUserID(r *http.Request) (userID uint64, err error) {
+// CreateMFAToken creates a token that lets the user finish a login by proving the second factor
+func CreateMFAToken(userID uint64) (string, error) {
+	tokenID, err := security.GenerateToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	return signToken(&Claims{
+		UserID:  userID,
+		Purpose: PurposeMFA,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(config.MFATokenDuration).Unix(),
+		},
+	})
+}
+
+// ValidateToken verify if the request carries a valid token that wasn't revoked, or an API key, returning its claims
+func ValidateToken(db *sql.DB, r *http.Request) (claims *Claims, err error) {
 	tokenString := extractToken(r)
-	token, err := jwt.Parse(tokenString, getVerificationKey)
+	if strings.HasPrefix(tokenString, APIKeyPrefix) {
+		return validateAPIKey(r.Context(), db, tokenString)
+	}
+
+	claims, err = parseToken(tokenString)
 	if err != nil {
 		return
 	}
 
-	if permissions, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID, err = strconv.ParseUint(fmt.Sprintf("%.0f", permissions["userID"]), 10, 64)
-		if err != nil {
-			return
-		}
+	if claims.Purpose != "" {
+		return nil, errors.New("this token can't be used to authenticate requests")
+	}
+
+	if err = checkRevocation(r.Context(), db, claims); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// ValidateMFAToken verify a token created by CreateMFAToken, returning its claims
+func ValidateMFAToken(ctx context.Context, db *sql.DB, tokenString string) (claims *Claims, err error) {
+	claims, err = parseToken(tokenString)
+	if err != nil {
 		return
 	}
 
-	err = errors.New("invalid Token")
+	if claims.Purpose != PurposeMFA {
+		return nil, errors.New("invalid MFA token")
+	}
+
+	if err = checkRevocation(ctx, db, claims); err != nil {
+		return nil, err
+	}
+
 	return
 }
 
+func parseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, getVerificationKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid Token")
+	}
+
+	return claims, nil
+}
+
 func extractToken(r *http.Request) string {
 	token := r.Header.Get("Authorization")
 
@@ -59,11 +137,3 @@ func extractToken(r *http.Request) string {
 
 	return ""
 }
-
-func getVerificationKey(token *jwt.Token) (interface{}, error) {
-	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-		return nil, fmt.Errorf("unexpected signed method! %v", token.Header["alg"])
-	}
-
-	return []byte(config.SecretKey), nil
-}
@@ -3,22 +3,167 @@ package config
 import (
 	"fmt"
 	"log"
+	"math"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 var (
-	// DBConnectionString is the string that connects to MySQL
+	// DBDriver is the database the API runs on: mysql, postgres or sqlite
+	DBDriver = "mysql"
+
+	// DBConnectionString is the string that connects to the database of DBDriver
 	DBConnectionString = ""
 
 	// DBConfig is the database connection configuration
 	DBConfig = "charset=utf8&parseTime=True&loc=Local"
 
+	// DBMaxOpenConnections and DBMaxIdleConnections size the pool of connections to the database
+	DBMaxOpenConnections = 25
+	DBMaxIdleConnections = 10
+
+	// DBConnectionMaxLifetime is how long a connection is reused before being replaced, and DBConnectionMaxIdleTime
+	// how long it can stay unused on the pool
+	DBConnectionMaxLifetime = 5 * time.Minute
+	DBConnectionMaxIdleTime = time.Minute
+
+	// DBRequestTimeout is how long the database can work on a request before it is canceled, 0 to wait forever
+	DBRequestTimeout = 10 * time.Second
+
+	// DBDeadlockRetries is how many times a transaction that lost a deadlock runs again before failing
+	DBDeadlockRetries = 3
+
+	// DBReplicas are the connection strings of the read replicas of the database, on the same DBDriver
+	DBReplicas []string
+
+	// DBReplicaMaxLag is how far behind the primary a replica can be before its reads go to the primary, checked
+	// every DBReplicaCheckInterval
+	DBReplicaMaxLag        = 5 * time.Second
+	DBReplicaCheckInterval = 5 * time.Second
+
+	// DBReadYourWritesWindow is how long the reads of an user go to the primary after it writes, so it sees its
+	// own changes while the replicas catch up
+	DBReadYourWritesWindow = 5 * time.Second
+
 	// Port describe where the API will be running
 	Port      = 0
 	SecretKey []byte
+
+	// AccessTokenDuration is how long an access token is valid after being issued
+	AccessTokenDuration = 15 * time.Minute
+
+	// RefreshTokenDuration is how long a refresh token can be exchanged for a new access token
+	RefreshTokenDuration = 30 * 24 * time.Hour
+
+	// RevocationCacheDuration is how long the API trusts its cached answer about a token being revoked
+	RevocationCacheDuration = 30 * time.Second
+
+	// MFATokenDuration is how long an user has to send the second factor after the password
+	MFATokenDuration = 5 * time.Minute
+
+	// TOTPIssuer is the name shown by authenticator apps next to the account
+	TOTPIssuer = "SocialMedia"
+
+	// ImpersonationDuration is how long an admin can act as another user with a single impersonation
+	ImpersonationDuration = 15 * time.Minute
+
+	// DeletedRetention is how long deleted users and posts can be restored before they are purged, checked every
+	// DeletedPurgeInterval
+	DeletedRetention     = 30 * 24 * time.Hour
+	DeletedPurgeInterval = time.Hour
+
+	// OAuthCodeDuration is how long an OAuth client has to exchange an authorization code for tokens
+	OAuthCodeDuration = time.Minute
+
+	// LoginMaxFailuresPerAccount and LoginMaxFailuresPerIP are how many failed logins are allowed before a lockout
+	LoginMaxFailuresPerAccount = 5
+	LoginMaxFailuresPerIP      = 20
+
+	// LoginBaseLockout is the first lockout, doubled on every new failure up to LoginMaxLockout
+	LoginBaseLockout = 30 * time.Second
+	LoginMaxLockout  = time.Hour
+
+	// TrustProxyHeaders makes the API read the client IP from X-Forwarded-For, when running behind a proxy
+	TrustProxyHeaders = false
+
+	// PasswordHashAlgorithm is the algorithm of new password hashes: argon2id or bcrypt
+	PasswordHashAlgorithm = "argon2id"
+
+	// Argon2Memory (in KiB), Argon2Iterations and Argon2Parallelism are the cost parameters of Argon2id
+	Argon2Memory      uint32 = 64 * 1024
+	Argon2Iterations  uint32 = 3
+	Argon2Parallelism uint8  = 2
+
+	// BcryptCost is the cost of bcrypt hashes
+	BcryptCost = 10
+
+	// PasswordMinLength is the minimum amount of characters of a password
+	PasswordMinLength = 8
+
+	// PasswordMinScore is the minimum strength of a password, from 0 (too guessable) to 4 (very unguessable)
+	PasswordMinScore = 3
+
+	// BreachedPasswordsPath is a directory of SHA-1 prefix files or a file of SHA-1 hashes of breached passwords
+	BreachedPasswordsPath = ""
+
+	// PasswordResetDuration is how long a password reset link is valid
+	PasswordResetDuration = time.Hour
+
+	// EmailVerificationDuration is how long an email verification link is valid
+	EmailVerificationDuration = 24 * time.Hour
+
+	// RequireVerifiedEmailToPost blocks users that didn't verify their email from posting
+	RequireVerifiedEmailToPost = false
+
+	// AppURL is the address of the application that receives the links sent by email
+	AppURL = "http://localhost:3000"
+
+	// MailDriver chooses how emails are sent: smtp, file or stdout
+	MailDriver = "stdout"
+
+	// MailFrom is the sender address of the emails
+	MailFrom = ""
+
+	// MailFile is where the emails are written when MailDriver is file
+	MailFile = "mails.log"
+
+	// SMTPHost, SMTPPort, SMTPUsername and SMTPPassword configure the SMTP server when MailDriver is smtp
+	SMTPHost     = ""
+	SMTPPort     = 587
+	SMTPUsername = ""
+	SMTPPassword = ""
+
+	// JWTSigningKeys maps each key ID (kid) to the PEM file of its private key. When empty, tokens are signed with SecretKey
+	JWTSigningKeys = map[string]string{}
+
+	// JWTActiveKey is the key ID used to sign new tokens
+	JWTActiveKey = ""
+
+	// JWTRetiredKeys maps the key IDs that don't sign anymore to when they were retired
+	JWTRetiredKeys = map[string]time.Time{}
+
+	// JWTKeyGracePeriod is how long tokens signed by a retired key are still accepted
+	JWTKeyGracePeriod = 24 * time.Hour
+
+	// APIURL is the public address of the API, where OpenID Connect providers send the users back to
+	APIURL = ""
+
+	// OIDCDiscoveryURLs maps each OpenID Connect provider name to its discovery document URL
+	OIDCDiscoveryURLs = map[string]string{}
+
+	// OIDCClientIDs maps each OpenID Connect provider name to the client ID of the API on it
+	OIDCClientIDs = map[string]string{}
+
+	// OIDCClientSecrets maps each OpenID Connect provider name to the client secret of the API on it
+	OIDCClientSecrets = map[string]string{}
+
+	// OIDCStateDuration is how long an user has to sign in on the OpenID Connect provider
+	OIDCStateDuration = 10 * time.Minute
 )
 
 // Load is going to initialize ambient variables
@@ -34,12 +179,192 @@ func Load() {
 		Port = 9000
 	}
 
-	DBConnectionString = fmt.Sprintf("%s:%s@/%s?%s",
+	DBDriver = loadString("DB_DRIVER", DBDriver)
+	DBConnectionString = connectionString(DBDriver)
+	DBMaxOpenConnections = loadInt("DB_MAX_OPEN_CONNECTIONS", DBMaxOpenConnections)
+	DBMaxIdleConnections = loadInt("DB_MAX_IDLE_CONNECTIONS", DBMaxIdleConnections)
+	DBConnectionMaxLifetime = loadDuration("DB_CONNECTION_MAX_LIFETIME", DBConnectionMaxLifetime)
+	DBConnectionMaxIdleTime = loadDuration("DB_CONNECTION_MAX_IDLE_TIME", DBConnectionMaxIdleTime)
+	DBRequestTimeout = loadDuration("DB_REQUEST_TIMEOUT", DBRequestTimeout)
+	DBDeadlockRetries = loadInt("DB_DEADLOCK_RETRIES", DBDeadlockRetries)
+	DBReplicas = loadList("DB_REPLICAS")
+	DBReplicaMaxLag = loadDuration("DB_REPLICA_MAX_LAG", DBReplicaMaxLag)
+	DBReplicaCheckInterval = loadDuration("DB_REPLICA_CHECK_INTERVAL", DBReplicaCheckInterval)
+	DBReadYourWritesWindow = loadDuration("DB_READ_YOUR_WRITES_WINDOW", DBReadYourWritesWindow)
+
+	SecretKey = []byte(os.Getenv("SECRET_KEY"))
+
+	AccessTokenDuration = loadDuration("ACCESS_TOKEN_DURATION", AccessTokenDuration)
+	RefreshTokenDuration = loadDuration("REFRESH_TOKEN_DURATION", RefreshTokenDuration)
+	RevocationCacheDuration = loadDuration("REVOCATION_CACHE_DURATION", RevocationCacheDuration)
+	MFATokenDuration = loadDuration("MFA_TOKEN_DURATION", MFATokenDuration)
+
+	TOTPIssuer = loadString("TOTP_ISSUER", TOTPIssuer)
+	OAuthCodeDuration = loadDuration("OAUTH_CODE_DURATION", OAuthCodeDuration)
+	ImpersonationDuration = loadDuration("IMPERSONATION_DURATION", ImpersonationDuration)
+	DeletedRetention = loadDuration("DELETED_RETENTION", DeletedRetention)
+	DeletedPurgeInterval = loadDuration("DELETED_PURGE_INTERVAL", DeletedPurgeInterval)
+
+	LoginMaxFailuresPerAccount = loadInt("LOGIN_MAX_FAILURES_PER_ACCOUNT", LoginMaxFailuresPerAccount)
+	LoginMaxFailuresPerIP = loadInt("LOGIN_MAX_FAILURES_PER_IP", LoginMaxFailuresPerIP)
+	LoginBaseLockout = loadDuration("LOGIN_BASE_LOCKOUT", LoginBaseLockout)
+	LoginMaxLockout = loadDuration("LOGIN_MAX_LOCKOUT", LoginMaxLockout)
+	TrustProxyHeaders = loadBool("TRUST_PROXY_HEADERS", TrustProxyHeaders)
+
+	PasswordHashAlgorithm = loadString("PASSWORD_HASH_ALGORITHM", PasswordHashAlgorithm)
+	Argon2Memory = uint32(loadIntBetween("ARGON2_MEMORY", int(Argon2Memory), 1, math.MaxUint32))
+	Argon2Iterations = uint32(loadIntBetween("ARGON2_ITERATIONS", int(Argon2Iterations), 1, math.MaxUint32))
+	Argon2Parallelism = uint8(loadIntBetween("ARGON2_PARALLELISM", int(Argon2Parallelism), 1, math.MaxUint8))
+	BcryptCost = loadInt("BCRYPT_COST", BcryptCost)
+
+	PasswordMinLength = loadInt("PASSWORD_MIN_LENGTH", PasswordMinLength)
+	PasswordMinScore = loadInt("PASSWORD_MIN_SCORE", PasswordMinScore)
+	BreachedPasswordsPath = os.Getenv("BREACHED_PASSWORDS_PATH")
+
+	PasswordResetDuration = loadDuration("PASSWORD_RESET_DURATION", PasswordResetDuration)
+	AppURL = loadString("APP_URL", AppURL)
+
+	EmailVerificationDuration = loadDuration("EMAIL_VERIFICATION_DURATION", EmailVerificationDuration)
+	RequireVerifiedEmailToPost = loadBool("REQUIRE_VERIFIED_EMAIL_TO_POST", RequireVerifiedEmailToPost)
+
+	MailDriver = loadString("MAIL_DRIVER", MailDriver)
+	MailFrom = os.Getenv("MAIL_FROM")
+	MailFile = loadString("MAIL_FILE", MailFile)
+
+	SMTPHost = os.Getenv("SMTP_HOST")
+	SMTPUsername = os.Getenv("SMTP_USERNAME")
+	SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	SMTPPort = loadInt("SMTP_PORT", SMTPPort)
+
+	JWTSigningKeys = loadMap("JWT_SIGNING_KEYS")
+	JWTActiveKey = os.Getenv("JWT_ACTIVE_KEY")
+	JWTKeyGracePeriod = loadDuration("JWT_KEY_GRACE_PERIOD", JWTKeyGracePeriod)
+
+	JWTRetiredKeys = map[string]time.Time{}
+	for keyID, retiredAt := range loadMap("JWT_RETIRED_KEYS") {
+		JWTRetiredKeys[keyID], err = time.Parse(time.RFC3339, retiredAt)
+		if err != nil {
+			log.Fatalf("invalid retirement date for the key %s: %v", keyID, err)
+		}
+	}
+
+	APIURL = strings.TrimSuffix(loadString("API_URL", fmt.Sprintf("http://localhost:%d", Port)), "/")
+	OIDCDiscoveryURLs = loadMap("OIDC_DISCOVERY_URLS")
+	OIDCClientIDs = loadMap("OIDC_CLIENT_IDS")
+	OIDCClientSecrets = loadMap("OIDC_CLIENT_SECRETS")
+	OIDCStateDuration = loadDuration("OIDC_STATE_DURATION", OIDCStateDuration)
+}
+
+// connectionString builds the string that connects to the database of the driver. DB_HOST is the host and
+// port of the server, and SQLite takes DB_NAME as the path of its file
+func connectionString(driver string) string {
+	host := os.Getenv("DB_HOST")
+
+	switch driver {
+	case "postgres":
+		if host == "" {
+			host = "localhost"
+		}
+
+		connectionURL := url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD")),
+			Host:   host,
+			Path:   os.Getenv("DB_NAME"),
+		}
+		return connectionURL.String()
+
+	case "sqlite":
+		return fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)",
+			loadString("DB_NAME", "socialmedia.db"))
+	}
+
+	if host != "" {
+		host = fmt.Sprintf("tcp(%s)", host)
+	}
+
+	return fmt.Sprintf("%s:%s@%s/%s?%s",
 		os.Getenv("DB_USER"),
 		os.Getenv("DB_PASSWORD"),
+		host,
 		os.Getenv("DB_NAME"),
 		DBConfig,
 	)
+}
 
-	SecretKey = []byte(os.Getenv("SECRET_KEY"))
+// loadString reads a string from the environment, keeping the default when it is missing
+func loadString(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+// loadInt reads an integer from the environment, keeping the default when it is missing or invalid
+func loadInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// loadIntBetween reads an integer like loadInt, stopping the API when it is outside of min and max, where it would
+// be truncated or break what uses it
+func loadIntBetween(key string, defaultValue, min, max int) int {
+	value := loadInt(key, defaultValue)
+	if value < min || value > max {
+		log.Fatalf("invalid %s %d, it must be between %d and %d", key, value, min, max)
+	}
+
+	return value
+}
+
+// loadBool reads a boolean (true, false, 1, 0...) from the environment, keeping the default when it is missing or invalid
+func loadBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// loadDuration reads a duration (e.g. "15m", "720h") from the environment, keeping the default when it is missing or invalid
+func loadDuration(key string, defaultValue time.Duration) time.Duration {
+	duration, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+
+	return duration
+}
+
+// loadList reads a comma separated list (e.g. "a,b") from the environment, skipping the empty items
+func loadList(key string) (values []string) {
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return
+}
+
+// loadMap reads a comma separated list of key=value pairs (e.g. "a=1,b=2") from the environment
+func loadMap(key string) map[string]string {
+	values := map[string]string{}
+
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			continue
+		}
+
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return values
 }
@@ -0,0 +1,404 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/database"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/templates"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// FindRoles gets all the roles with their permissions
+func (controllers *Controllers) FindRoles(w http.ResponseWriter, r *http.Request) {
+	db := controllers.db
+
+	rolesRepository := repositories.NewRolesRepository(db)
+	roles, err := rolesRepository.SearchAll(r.Context())
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, roles)
+}
+
+// FindUserRoles gets the roles of an user, including the base role every user has
+func (controllers *Controllers) FindUserRoles(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	db := controllers.db
+
+	rolesRepository := repositories.NewRolesRepository(db)
+	roles, permissions, err := rolesRepository.SearchUserRoles(r.Context(), userID, authentication.RoleUser)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, struct {
+		Roles       []string `json:"roles"`
+		Permissions []string `json:"permissions"`
+	}{roles, permissions})
+}
+
+// GrantRole grants a role to an user
+func (controllers *Controllers) GrantRole(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	bodyRequest, err := io.ReadAll(r.Body)
+	if err != nil {
+		templates.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var roleGrant models.RoleGrant
+	if err = json.Unmarshal(bodyRequest, &roleGrant); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = roleGrant.Prepare(); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if roleGrant.Role == authentication.RoleUser {
+		templates.Error(w, http.StatusBadRequest, errors.New("every user already has the user role"))
+		return
+	}
+
+	db := controllers.db
+
+	user, err := controllers.users.SerachByID(r.Context(), userID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if user.ID == 0 {
+		templates.Error(w, http.StatusNotFound, errors.New("user not found"))
+		return
+	}
+
+	rolesRepository := repositories.NewRolesRepository(db)
+	exists, err := rolesRepository.Exists(r.Context(), roleGrant.Role)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !exists {
+		templates.Error(w, http.StatusBadRequest, errors.New("unknown role"))
+		return
+	}
+
+	if err = rolesRepository.Grant(r.Context(), userID, roleGrant.Role); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
+
+// RevokeRole revokes a role of an user. Its access tokens stop working, so the next ones come without the role
+func (controllers *Controllers) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	role := params["role"]
+
+	db := controllers.db
+
+	rolesRepository := repositories.NewRolesRepository(db)
+	if role == authentication.RoleAdmin {
+		lastAdmin, err := isLastAdmin(r.Context(), rolesRepository, userID)
+		if err != nil {
+			templates.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if lastAdmin {
+			templates.Error(w, http.StatusConflict, errors.New("its not possible to revoke the role of the last admin"))
+			return
+		}
+	}
+
+	revoked, err := rolesRepository.Revoke(r.Context(), userID, role)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !revoked {
+		templates.Error(w, http.StatusNotFound, errors.New("the user doesn't have this role"))
+		return
+	}
+
+	if err = authentication.InvalidateAccessTokens(r.Context(), db, userID); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
+
+// isLastAdmin reports if the user is the only admin left, who can't lose the role nor be deleted, or nobody could
+// manage the users anymore
+func isLastAdmin(ctx context.Context, rolesRepository *repositories.RolesRepository, userID uint64) (lastAdmin bool, err error) {
+	roles, _, err := rolesRepository.SearchUserRoles(ctx, userID, authentication.RoleUser)
+	if err != nil {
+		return
+	}
+
+	for _, role := range roles {
+		if role != authentication.RoleAdmin {
+			continue
+		}
+
+		admins, err := rolesRepository.CountUsers(ctx, authentication.RoleAdmin)
+		return admins <= 1, err
+	}
+
+	return
+}
+
+// FindLockoutEvents gets the latest lockouts caused by failed logins
+func (controllers *Controllers) FindLockoutEvents(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	db := controllers.db
+
+	lockoutEventsRepository := repositories.NewLockoutEventsRepository(db)
+	lockoutEvents, err := lockoutEventsRepository.Search(r.Context(), limit)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, lockoutEvents)
+}
+
+// FindDatabaseStats gets the statistics of the pool of connections to the database
+func (controllers *Controllers) FindDatabaseStats(w http.ResponseWriter, r *http.Request) {
+	templates.JSON(w, http.StatusOK, database.Stats())
+}
+
+// Impersonate gives the admin a short lived token to act as another user, recording it on the audit trail
+func (controllers *Controllers) Impersonate(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	targetID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	actorID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if targetID == actorID {
+		templates.Error(w, http.StatusBadRequest, errors.New("its not possible to impersonate yourself"))
+		return
+	}
+
+	bodyRequest, err := io.ReadAll(r.Body)
+	if err != nil {
+		templates.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var impersonationStart models.ImpersonationStart
+	if err = json.Unmarshal(bodyRequest, &impersonationStart); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = impersonationStart.Prepare(); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	db := controllers.db
+
+	target, err := controllers.users.SerachByID(r.Context(), targetID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if target.ID == 0 {
+		templates.Error(w, http.StatusNotFound, errors.New("user not found"))
+		return
+	}
+
+	rolesRepository := repositories.NewRolesRepository(db)
+	_, targetPermissions, err := rolesRepository.SearchUserRoles(r.Context(), targetID, authentication.RoleUser)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Acting as someone who can impersonate would be a way around the audit trail
+	for _, permission := range targetPermissions {
+		if permission == authentication.PermissionUsersImpersonate {
+			templates.Error(w, http.StatusForbidden, errors.New("its not possible to impersonate users that can impersonate"))
+			return
+		}
+	}
+
+	readOnly := !impersonationStart.AllowWrites
+	token, claims, err := authentication.CreateImpersonationToken(targetID, actorID, readOnly)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	impersonationsRepository := repositories.NewImpersonationsRepository(db)
+	impersonationID, err := impersonationsRepository.Create(r.Context(), models.Impersonation{
+		ActorID:   actorID,
+		TargetID:  targetID,
+		Reason:    impersonationStart.Reason,
+		ReadOnly:  readOnly,
+		TokenID:   claims.Id,
+		StartedAt: time.Unix(claims.IssuedAt, 0),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	})
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Printf("user %d started impersonating user %d (impersonation %d): %s", actorID, targetID, impersonationID, impersonationStart.Reason)
+
+	templates.JSON(w, http.StatusCreated, models.ImpersonationToken{
+		ImpersonationID: impersonationID,
+		AccessToken:     token,
+		TokenType:       "Bearer",
+		ExpiresIn:       claims.ExpiresAt - claims.IssuedAt,
+		ReadOnly:        readOnly,
+	})
+}
+
+// FindImpersonations gets the latest impersonations
+func (controllers *Controllers) FindImpersonations(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	db := controllers.db
+
+	impersonationsRepository := repositories.NewImpersonationsRepository(db)
+	impersonations, err := impersonationsRepository.Search(r.Context(), limit)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, impersonations)
+}
+
+// FindImpersonatedRequests gets the requests made during an impersonation
+func (controllers *Controllers) FindImpersonatedRequests(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	impersonationID, err := strconv.ParseUint(params["impersonationId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	db := controllers.db
+
+	impersonationsRepository := repositories.NewImpersonationsRepository(db)
+	requests, err := impersonationsRepository.SearchRequests(r.Context(), impersonationID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, requests)
+}
+
+// EndImpersonation ends an impersonation before its token expires
+func (controllers *Controllers) EndImpersonation(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	impersonationID, err := strconv.ParseUint(params["impersonationId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	db := controllers.db
+
+	impersonationsRepository := repositories.NewImpersonationsRepository(db)
+	impersonation, err := impersonationsRepository.SearchByID(r.Context(), impersonationID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if impersonation.ID == 0 {
+		templates.Error(w, http.StatusNotFound, errors.New("impersonation not found"))
+		return
+	}
+
+	if err = authentication.EndImpersonation(r.Context(), db, impersonation.TokenID); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
+
+// RestoreUser brings back a deleted user, with its posts and follows, while it is within DELETED_RETENTION. Its
+// tokens stay revoked, so it has to login again
+func (controllers *Controllers) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	restored, err := controllers.users.Restore(r.Context(), userID, time.Now().Add(-config.DeletedRetention))
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !restored {
+		templates.Error(w, http.StatusNotFound, errors.New("there is no deleted user with this ID that can still be restored"))
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/security"
+	"api/src/templates"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateAPIKey creates a personal API key for the user. The key is only shown on this response
+func (controllers *Controllers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenUserID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userID != tokenUserID {
+		templates.Error(w, http.StatusForbidden, errors.New("its not possible to create API keys for other users"))
+		return
+	}
+
+	bodyRequest, err := io.ReadAll(r.Body)
+	if err != nil {
+		templates.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var apiKey models.APIKey
+	if err = json.Unmarshal(bodyRequest, &apiKey); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = apiKey.Prepare(); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	for _, scope := range apiKey.Scopes {
+		if !authentication.ValidScope(scope) {
+			templates.Error(w, http.StatusBadRequest, fmt.Errorf("unknown scope %q", scope))
+			return
+		}
+	}
+
+	apiKey.UserID = userID
+	apiKey.Key, apiKey.Prefix, err = authentication.GenerateAPIKey()
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	apiKey.KeyHash = security.HashToken(apiKey.Key)
+
+	db := controllers.db
+
+	apiKeysRepository := repositories.NewAPIKeysRepository(db)
+	apiKey.ID, err = apiKeysRepository.Create(r.Context(), apiKey)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusCreated, apiKey)
+}
+
+// FindAPIKeys gets all the API keys of the user, without the keys themselves
+func (controllers *Controllers) FindAPIKeys(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenUserID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userID != tokenUserID {
+		templates.Error(w, http.StatusForbidden, errors.New("its not possible to see other users API keys"))
+		return
+	}
+
+	db := controllers.db
+
+	apiKeysRepository := repositories.NewAPIKeysRepository(db)
+	apiKeys, err := apiKeysRepository.SearchByUser(r.Context(), userID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, apiKeys)
+}
+
+// DeleteAPIKey revokes an API key of the user
+func (controllers *Controllers) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	apiKeyID, err := strconv.ParseUint(params["apiKeyId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenUserID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userID != tokenUserID {
+		templates.Error(w, http.StatusForbidden, errors.New("its not possible to delete other users API keys"))
+		return
+	}
+
+	db := controllers.db
+
+	apiKeysRepository := repositories.NewAPIKeysRepository(db)
+	deleted, err := apiKeysRepository.Delete(r.Context(), userID, apiKeyID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !deleted {
+		templates.Error(w, http.StatusNotFound, errors.New("API key not found"))
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
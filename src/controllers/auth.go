@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/database"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/security"
+	"api/src/templates"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// RefreshToken exchanges a refresh token for a new access token, rotating the refresh token
+func (controllers *Controllers) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		templates.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var refreshRequest models.RefreshRequest
+	if err = json.Unmarshal(requestBody, &refreshRequest); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if refreshRequest.RefreshToken == "" {
+		templates.Error(w, http.StatusBadRequest, errors.New(models.FieldisEmptyMessage("refreshToken")))
+		return
+	}
+
+	db := controllers.db
+
+	_, authenticationData, err := refreshSession(r.Context(), db, refreshRequest.RefreshToken, "")
+	if errors.Is(err, errInvalidRefreshToken) {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, authenticationData)
+}
+
+// Logout revokes the token used on the request and the refresh tokens of the same login, or ends the impersonation
+// of the token
+func (controllers *Controllers) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, err := authentication.ClaimsFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if claims.Actor != nil {
+		err = authentication.EndImpersonation(r.Context(), controllers.db, claims.Id)
+	} else {
+		err = authentication.RevokeToken(r.Context(), controllers.db, claims)
+	}
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
+
+// LogoutAll revokes every token issued to the authenticated user
+func (controllers *Controllers) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if err = authentication.RevokeUserTokens(r.Context(), controllers.db, userID); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
+
+// JWKS publishes the public keys that verify the tokens issued by the API
+func (controllers *Controllers) JWKS(w http.ResponseWriter, r *http.Request) {
+	templates.JSON(w, http.StatusOK, authentication.PublicKeys())
+}
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// refreshSession rotates a refresh token issued to the client (empty for tokens from a login), returning
+// the session it belongs to and its new tokens. Rejected refresh tokens wrap errInvalidRefreshToken
+func refreshSession(ctx context.Context, db *sql.DB, token, clientID string) (session models.Session, authenticationData models.AuthenticationData, err error) {
+	refreshTokensRepository := repositories.NewRefreshTokensRepository(db)
+	refreshToken, err := refreshTokensRepository.SearchByHash(ctx, security.HashToken(token))
+	if err != nil {
+		return
+	}
+
+	if refreshToken.ID == 0 || refreshToken.RevokedAt != nil || refreshToken.Expired() {
+		err = errInvalidRefreshToken
+		return
+	}
+
+	sessionsRepository := repositories.NewSessionsRepository(db)
+	if session, err = sessionsRepository.SearchByID(ctx, refreshToken.FamilyID); err != nil {
+		return
+	}
+
+	if session.ID == "" || session.RevokedAt != nil {
+		err = fmt.Errorf("%w: the session has ended, please login again", errInvalidRefreshToken)
+		return
+	}
+
+	if session.ClientID != clientID {
+		err = fmt.Errorf("%w: the token was issued to another client", errInvalidRefreshToken)
+		return
+	}
+
+	// The token is only spent when the new ones are issued
+	reused := false
+	if err = database.Transaction(ctx, db, func(tx *sql.Tx) (err error) {
+		refreshTokensRepository := repositories.NewRefreshTokensRepository(tx)
+
+		rotated := false
+		if refreshToken.RotatedAt == nil {
+			if rotated, err = refreshTokensRepository.Rotate(ctx, refreshToken.ID); err != nil {
+				return
+			}
+		}
+
+		if reused = !rotated; reused {
+			return
+		}
+
+		if authenticationData, err = issueTokens(ctx, tx, session); err != nil {
+			return
+		}
+
+		sessionsRepository := repositories.NewSessionsRepository(tx)
+		return sessionsRepository.Touch(ctx, session.ID)
+	}); err != nil {
+		return
+	}
+
+	// A refresh token that was already rotated means it leaked, so nobody holding this session can be trusted: its
+	// refresh tokens and its access tokens stop working
+	if reused {
+		if _, err = authentication.RevokeSession(ctx, db, session.UserID, session.ID); err != nil {
+			return
+		}
+
+		err = fmt.Errorf("%w: reuse detected, please login again", errInvalidRefreshToken)
+	}
+
+	return
+}
+
+// startSession records a new session for the user, from the device of the request, and issues its first tokens
+func startSession(db *sql.DB, r *http.Request, userID uint64) (authenticationData models.AuthenticationData, err error) {
+	err = database.Transaction(r.Context(), db, func(tx *sql.Tx) (err error) {
+		authenticationData, err = startClientSession(tx, r, models.Session{UserID: userID})
+		return
+	})
+	return
+}
+
+// startClientSession records a new session, filled with the device of the request, and issues its first tokens.
+// The session gets a new ID when it has none. Both are written on db, the transaction of the caller
+func startClientSession(db database.DBTX, r *http.Request, session models.Session) (authenticationData models.AuthenticationData, err error) {
+	if session.ID == "" {
+		if session.ID, err = security.GenerateToken(16); err != nil {
+			return
+		}
+	}
+
+	session.UserAgent = r.UserAgent()
+	if len(session.UserAgent) > 255 {
+		session.UserAgent = session.UserAgent[:255]
+	}
+	session.IP = clientIP(r)
+
+	sessionsRepository := repositories.NewSessionsRepository(db)
+	if err = sessionsRepository.Create(r.Context(), session); err != nil {
+		return
+	}
+
+	return issueTokens(r.Context(), db, session)
+}
+
+// issueTokens creates an access token and a new refresh token on the family of the session
+func issueTokens(ctx context.Context, db database.DBTX, session models.Session) (authenticationData models.AuthenticationData, err error) {
+	var accessToken string
+	if session.ClientID == "" {
+		var roles, permissions []string
+		rolesRepository := repositories.NewRolesRepository(db)
+		if roles, permissions, err = rolesRepository.SearchUserRoles(ctx, session.UserID, authentication.RoleUser); err != nil {
+			return
+		}
+
+		accessToken, err = authentication.CreateToken(session.UserID, session.ID, roles, permissions)
+	} else {
+		accessToken, err = authentication.CreateClientToken(session.UserID, session.ID, session.ClientID, session.Scopes)
+	}
+	if err != nil {
+		return
+	}
+
+	refreshToken, err := security.GenerateToken(32)
+	if err != nil {
+		return
+	}
+
+	refreshTokensRepository := repositories.NewRefreshTokensRepository(db)
+	if _, err = refreshTokensRepository.Create(ctx, models.RefreshToken{
+		UserID:    session.UserID,
+		FamilyID:  session.ID,
+		TokenHash: security.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(config.RefreshTokenDuration),
+	}); err != nil {
+		return
+	}
+
+	authenticationData = models.AuthenticationData{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(config.AccessTokenDuration.Seconds()),
+	}
+
+	return
+}
@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"api/src/repositories"
+	"database/sql"
+)
+
+// Controllers are the handlers of the API, with where they keep users and posts: the repositories on the
+// database when the API runs, or the ones from the repositories/memory package on tests
+type Controllers struct {
+	db    *sql.DB
+	users repositories.UserStore
+	posts repositories.PostStore
+}
+
+// New creates the handlers of the API, keeping everything besides users and posts on db
+func New(db *sql.DB, users repositories.UserStore, posts repositories.PostStore) *Controllers {
+	return &Controllers{db: db, users: users, posts: posts}
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/database"
+	"api/src/mail"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/security"
+	"api/src/templates"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// VerifyEmail confirms the user owns an email address with the token sent to it.
+// When the user was changing the email, the new one replaces the old one only now
+func (controllers *Controllers) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		templates.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var emailVerificationRequest models.EmailVerificationRequest
+	if err = json.Unmarshal(requestBody, &emailVerificationRequest); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if emailVerificationRequest.Token == "" {
+		templates.Error(w, http.StatusBadRequest, errors.New(models.FieldisEmptyMessage("token")))
+		return
+	}
+
+	db := controllers.db
+
+	emailVerificationsRepository := repositories.NewEmailVerificationsRepository(db)
+	emailVerification, err := emailVerificationsRepository.SearchByHash(r.Context(), security.HashToken(emailVerificationRequest.Token))
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	invalidTokenError := errors.New("the email verification token is invalid or expired")
+	if emailVerification.ID == 0 || emailVerification.UsedAt != nil || emailVerification.Expired() {
+		templates.Error(w, http.StatusBadRequest, invalidTokenError)
+		return
+	}
+
+	emailOwner, err := controllers.users.SearchByEmail(r.Context(), emailVerification.Email)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	emailInUse := emailOwner.ID != 0 && emailOwner.ID != emailVerification.UserID
+	if emailOwner.ID == 0 {
+		// A deleted user still holds its email until it is purged
+		if emailInUse, err = controllers.users.EmailExists(r.Context(), emailVerification.Email); err != nil {
+			templates.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if emailInUse {
+		templates.Error(w, http.StatusConflict, errors.New("the email is already in use by another user"))
+		return
+	}
+
+	// The token is only spent when the email is verified with it
+	err = database.Transaction(r.Context(), db, func(tx *sql.Tx) error {
+		emailVerificationsRepository := repositories.NewEmailVerificationsRepository(tx)
+		used, err := emailVerificationsRepository.Use(r.Context(), emailVerification.ID)
+		if err != nil {
+			return err
+		}
+
+		if !used {
+			return invalidTokenError
+		}
+
+		userRepository := controllers.users.WithTx(tx)
+		return userRepository.VerifyEmail(r.Context(), emailVerification.UserID, emailVerification.Email)
+	})
+	if errors.Is(err, invalidTokenError) {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
+
+// ResendEmailVerification sends a new verification link to the email waiting for verification of the authenticated
+// user: the new one when it is changing the email, or the current one when it wasn't verified yet
+func (controllers *Controllers) ResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	db := controllers.db
+
+	user, err := controllers.users.SerachByID(r.Context(), userID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	emailVerificationsRepository := repositories.NewEmailVerificationsRepository(db)
+	pendingVerification, err := emailVerificationsRepository.SearchLatestPending(r.Context(), userID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	email := pendingVerification.Email
+	if pendingVerification.ID == 0 {
+		if user.EmailVerifiedAt != nil {
+			templates.Error(w, http.StatusConflict, errors.New("there is no email waiting for verification"))
+			return
+		}
+
+		email = user.Email
+	}
+
+	if err = sendEmailVerification(r.Context(), db, user.ID, email); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusAccepted, nil)
+}
+
+// sendEmailVerification replaces the pending verifications of the user by a new one, sending its link to the email
+func sendEmailVerification(ctx context.Context, db *sql.DB, userID uint64, email string) (err error) {
+	token, err := security.GenerateToken(32)
+	if err != nil {
+		return
+	}
+
+	emailVerificationsRepository := repositories.NewEmailVerificationsRepository(db)
+	if err = emailVerificationsRepository.UseAllFromUser(ctx, userID); err != nil {
+		return
+	}
+
+	if err = emailVerificationsRepository.Create(ctx, models.EmailVerification{
+		UserID:    userID,
+		Email:     email,
+		TokenHash: security.HashToken(token),
+		ExpiresAt: time.Now().Add(config.EmailVerificationDuration),
+	}); err != nil {
+		return
+	}
+
+	go func() {
+		if err := mail.Send(emailVerificationMessage(email, token)); err != nil {
+			log.Printf("sending the email verification: %v", err)
+		}
+	}()
+
+	return
+}
+
+func emailVerificationMessage(email, token string) mail.Message {
+	link := fmt.Sprintf("%s/verify-email?token=%s", config.AppURL, url.QueryEscape(token))
+
+	return mail.Message{
+		To:      email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf(
+			"To confirm this is your email, open the link below in the next %s:\n\n%s\n\n"+
+				"If you didn't create an account or change your email, just ignore this email.",
+			config.EmailVerificationDuration,
+			link,
+		),
+	}
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	errMissingIfMatch  = errors.New("send the ETag of the version being changed on the If-Match header")
+	errVersionConflict = errors.New("it was changed since this version was read, get it again before changing it")
+)
+
+// etag is the entity tag of a version of a post or a profile
+func etag(version uint64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// checkIfMatch compares the If-Match header of the request with the version being changed. Without the header
+// the change is refused with errMissingIfMatch, answered with 428, and with another version it is refused with
+// an error answered with 412, since the client would overwrite changes it hasn't seen
+func checkIfMatch(r *http.Request, version uint64) error {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return errMissingIfMatch
+	}
+
+	// * matches any current version, and the callers only check resources that exist
+	if strings.TrimSpace(ifMatch) == "*" {
+		return nil
+	}
+
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(tag) == etag(version) {
+			return nil
+		}
+	}
+
+	return errVersionConflict
+}
+
+// preconditionStatus is the status code of a change refused by checkIfMatch
+func preconditionStatus(err error) int {
+	if errors.Is(err, errMissingIfMatch) {
+		return http.StatusPreconditionRequired
+	}
+
+	return http.StatusPreconditionFailed
+}
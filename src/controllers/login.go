@@ -2,18 +2,30 @@ package controllers
 
 import (
 	"api/src/authentication"
-	"api/src/database"
+	"api/src/config"
+	"api/src/lockout"
 	"api/src/models"
 	"api/src/repositories"
 	"api/src/security"
 	"api/src/templates"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
+var errInvalidCredentials = errors.New("invalid email or password")
+
 // Login authenticates a user on the API
-func Login(w http.ResponseWriter, r *http.Request) {
+func (controllers *Controllers) Login(w http.ResponseWriter, r *http.Request) {
 	requestBody, err := io.ReadAll(r.Body)
 	if err != nil {
 		templates.Error(w, http.StatusUnprocessableEntity, err)
@@ -26,25 +38,191 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := database.Connect()
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
+	accountKey := strings.ToLower(strings.TrimSpace(user.Email))
+	ip := clientIP(r)
+	if rejectLockedOut(w, accountKey, ip) {
 		return
 	}
-	defer db.Close()
 
-	userRepository := repositories.NewUserRepository(db)
-	userSavedOnDataBase, err := userRepository.SearchByEmail(user.Email)
+	db := controllers.db
+
+	userSavedOnDataBase, err := controllers.users.SearchByEmail(r.Context(), user.Email)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	// An unknown email gets the same answer, in the same time, as a wrong password
+	if userSavedOnDataBase.ID == 0 {
+		security.SimulatePasswordValidation(user.Password)
+		recordFailedLogin(r.Context(), db, accountKey, ip)
+		templates.Error(w, http.StatusUnauthorized, errInvalidCredentials)
+		return
+	}
+
 	if err = security.ValidatePassword(userSavedOnDataBase.Password, user.Password); err != nil {
+		recordFailedLogin(r.Context(), db, accountKey, ip)
+		templates.Error(w, http.StatusUnauthorized, errInvalidCredentials)
+		return
+	}
+	lockout.Accounts.Succeed(accountKey)
+
+	// Hashes made by an older algorithm or cost are replaced while the plain password is at hand
+	if security.NeedsRehash(userSavedOnDataBase.Password) {
+		if err = controllers.rehashPassword(r.Context(), userSavedOnDataBase.ID, user.Password); err != nil {
+			log.Printf("rehashing the password of the user %d: %v", userSavedOnDataBase.ID, err)
+		}
+	}
+
+	controllers.finishLogin(w, r, userSavedOnDataBase.ID)
+}
+
+// LoginMFA finishes a login that requires the second factor, exchanging the MFA token and a code for the tokens
+func (controllers *Controllers) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		templates.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var mfaLogin models.MFALogin
+	if err = json.Unmarshal(requestBody, &mfaLogin); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	claims, err := authentication.ValidateMFAToken(r.Context(), controllers.db, mfaLogin.MFAToken)
+	if err != nil {
 		templates.Error(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	token, _ := authentication.CreateToken(userSavedOnDataBase.ID)
-	w.Write([]byte(token))
+	accountKey := fmt.Sprintf("mfa:%d", claims.UserID)
+	ip := clientIP(r)
+	if rejectLockedOut(w, accountKey, ip) {
+		return
+	}
+
+	db := controllers.db
+
+	verified, err := controllers.verifySecondFactor(r.Context(), claims.UserID, mfaLogin.SecondFactor)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !verified {
+		recordFailedLogin(r.Context(), db, accountKey, ip)
+		templates.Error(w, http.StatusUnauthorized, errors.New("invalid two-factor authentication code"))
+		return
+	}
+	lockout.Accounts.Succeed(accountKey)
+
+	if err = authentication.RevokeToken(r.Context(), db, claims); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	authenticationData, err := startSession(db, r, claims.UserID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, authenticationData)
+}
+
+// finishLogin answers a login whose first factor was proven, with the tokens of a new session or,
+// when the user enabled the two-factor authentication, with the challenge of the second factor
+func (controllers *Controllers) finishLogin(w http.ResponseWriter, r *http.Request, userID uint64) {
+	totp, err := controllers.users.SearchTOTP(r.Context(), userID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if totp.Enabled {
+		mfaToken, err := authentication.CreateMFAToken(userID)
+		if err != nil {
+			templates.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		templates.JSON(w, http.StatusAccepted, models.MFAChallenge{
+			MFAToken:  mfaToken,
+			ExpiresIn: int64(config.MFATokenDuration.Seconds()),
+		})
+		return
+	}
+
+	authenticationData, err := startSession(controllers.db, r, userID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, authenticationData)
+}
+
+// rehashPassword stores a new hash of the password made with the configured algorithm
+func (controllers *Controllers) rehashPassword(ctx context.Context, userID uint64, password string) error {
+	hashedPassword, err := security.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = controllers.users.UpdatePassword(ctx, userID, string(hashedPassword))
+	return err
+}
+
+// rejectLockedOut answers with 429 when the account or the IP is locked out, reporting if it did
+func rejectLockedOut(w http.ResponseWriter, accountKey, ip string) bool {
+	retryAfter := max(lockout.Accounts.RetryAfter(accountKey), lockout.IPs.RetryAfter(ip))
+	if retryAfter == 0 {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	templates.Error(w, http.StatusTooManyRequests, errors.New("too many failed login attempts, try again later"))
+	return true
+}
+
+// recordFailedLogin counts a failed login for the account and for the IP, recording the lockouts it causes
+func recordFailedLogin(ctx context.Context, db *sql.DB, accountKey, ip string) {
+	recordFailure(ctx, db, "account", accountKey, lockout.Accounts)
+	recordFailure(ctx, db, "ip", ip, lockout.IPs)
+}
+
+func recordFailure(ctx context.Context, db *sql.DB, kind, identifier string, tracker *lockout.Tracker) {
+	failures, lockedUntil := tracker.Fail(identifier)
+	if lockedUntil.IsZero() {
+		return
+	}
+
+	lockoutEventsRepository := repositories.NewLockoutEventsRepository(db)
+	if err := lockoutEventsRepository.Create(ctx, models.LockoutEvent{
+		Kind:        kind,
+		Identifier:  identifier,
+		Failures:    failures,
+		LockedUntil: lockedUntil,
+	}); err != nil {
+		log.Printf("recording the lockout of the %s %s: %v", kind, identifier, err)
+	}
+}
+
+// clientIP gets the IP of the client, trusting X-Forwarded-For only when the API is configured to
+func clientIP(r *http.Request) string {
+	if config.TrustProxyHeaders {
+		if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+			ip, _, _ := strings.Cut(forwardedFor, ",")
+			return strings.TrimSpace(ip)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
 }
@@ -0,0 +1,594 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/database"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/security"
+	"api/src/templates"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateOAuthClient registers a third-party application owned by the user. The secret of confidential
+// clients is only shown on this response
+func (controllers *Controllers) CreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	bodyRequest, err := io.ReadAll(r.Body)
+	if err != nil {
+		templates.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var client models.OAuthClient
+	if err = json.Unmarshal(bodyRequest, &client); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = client.Prepare(); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	client.UserID = userID
+	if client.ClientID, err = security.GenerateToken(16); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if client.Confidential {
+		if client.ClientSecret, err = security.GenerateToken(32); err != nil {
+			templates.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		client.SecretHash = security.HashToken(client.ClientSecret)
+	}
+
+	db := controllers.db
+
+	oauthClientsRepository := repositories.NewOAuthClientsRepository(db)
+	client.ID, err = oauthClientsRepository.Create(r.Context(), client)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusCreated, client)
+}
+
+// FindOAuthClients gets the OAuth clients registered by the user
+func (controllers *Controllers) FindOAuthClients(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	db := controllers.db
+
+	oauthClientsRepository := repositories.NewOAuthClientsRepository(db)
+	clients, err := oauthClientsRepository.SearchByUser(r.Context(), userID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, clients)
+}
+
+// DeleteOAuthClient deletes an OAuth client registered by the user, ending every session it holds
+func (controllers *Controllers) DeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	clientID := mux.Vars(r)["clientId"]
+
+	userID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	db := controllers.db
+
+	oauthClientsRepository := repositories.NewOAuthClientsRepository(db)
+	deleted, err := oauthClientsRepository.Delete(r.Context(), userID, clientID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !deleted {
+		templates.Error(w, http.StatusNotFound, errors.New("OAuth client not found"))
+		return
+	}
+
+	if err = authentication.RevokeClientSessions(r.Context(), db, clientID, 0); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
+
+// OAuthConsent validates an authorization request, returning the client and the scopes the user is asked to approve
+func (controllers *Controllers) OAuthConsent(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	query := r.URL.Query()
+	authorizationRequest := models.OAuthAuthorizationRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+
+	db := controllers.db
+
+	client, scopes, oauthErr, err := checkAuthorizationRequest(r.Context(), db, authorizationRequest)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if oauthErr != nil {
+		templates.JSON(w, http.StatusBadRequest, oauthErr)
+		return
+	}
+
+	oauthGrantsRepository := repositories.NewOAuthGrantsRepository(db)
+	grant, _, err := oauthGrantsRepository.Search(r.Context(), userID, client.ClientID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, models.OAuthConsent{
+		Client:        client,
+		Scopes:        scopes,
+		GrantedScopes: grant.Scopes,
+	})
+}
+
+// Authorize records the answer of the user to an authorization request, returning where the user agent
+// must be redirected to, with an authorization code when the user approved it
+func (controllers *Controllers) Authorize(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	bodyRequest, err := io.ReadAll(r.Body)
+	if err != nil {
+		templates.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var authorizationRequest models.OAuthAuthorizationRequest
+	if err = json.Unmarshal(bodyRequest, &authorizationRequest); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	db := controllers.db
+
+	// Only a valid client and redirect URI can receive the errors, any other problem goes back to the client
+	client, scopes, oauthErr, err := checkAuthorizationRequest(r.Context(), db, authorizationRequest)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result := url.Values{}
+	if authorizationRequest.State != "" {
+		result.Set("state", authorizationRequest.State)
+	}
+
+	if oauthErr == nil && !authorizationRequest.Approve {
+		oauthErr = &models.OAuthError{Error: "access_denied", ErrorDescription: "the user denied the authorization"}
+	}
+
+	if oauthErr != nil {
+		result.Set("error", oauthErr.Error)
+		result.Set("error_description", oauthErr.ErrorDescription)
+		templates.JSON(w, http.StatusOK, models.OAuthRedirect{RedirectURI: withQuery(authorizationRequest.RedirectURI, result)})
+		return
+	}
+
+	if err = saveGrant(r.Context(), db, userID, client.ClientID, scopes); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	code, err := security.GenerateToken(32)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	codesRepository := repositories.NewOAuthAuthorizationCodesRepository(db)
+	if err = codesRepository.Create(r.Context(), models.OAuthAuthorizationCode{
+		CodeHash:      security.HashToken(code),
+		ClientID:      client.ClientID,
+		UserID:        userID,
+		RedirectURI:   authorizationRequest.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: authorizationRequest.CodeChallenge,
+		ExpiresAt:     time.Now().Add(config.OAuthCodeDuration),
+	}); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = codesRepository.DeleteExpired(r.Context()); err != nil {
+		log.Printf("deleting the expired OAuth authorization codes: %v", err)
+	}
+
+	result.Set("code", code)
+	templates.JSON(w, http.StatusOK, models.OAuthRedirect{RedirectURI: withQuery(authorizationRequest.RedirectURI, result)})
+}
+
+// OAuthToken exchanges an authorization code or a refresh token for tokens of the client
+func (controllers *Controllers) OAuthToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	db := controllers.db
+
+	client, err := authenticateClient(db, r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		oauthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	var session models.Session
+	var authenticationData models.AuthenticationData
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		session, authenticationData, err = exchangeAuthorizationCode(db, r, client)
+	case "refresh_token":
+		session, authenticationData, err = refreshSession(r.Context(), db, r.PostForm.Get("refresh_token"), client.ClientID)
+	default:
+		oauthError(w, http.StatusBadRequest, "unsupported_grant_type", "the grant type must be authorization_code or refresh_token")
+		return
+	}
+
+	if errors.Is(err, errInvalidRefreshToken) || errors.Is(err, errInvalidAuthorizationCode) {
+		oauthError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	if err != nil {
+		oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, models.OAuthToken{
+		AccessToken:  authenticationData.AccessToken,
+		TokenType:    authenticationData.TokenType,
+		ExpiresIn:    authenticationData.ExpiresIn,
+		RefreshToken: authenticationData.RefreshToken,
+		Scope:        strings.Join(session.Scopes, " "),
+	})
+}
+
+// RevokeOAuthToken revokes an access or refresh token of the client, ending the session it belongs to
+func (controllers *Controllers) RevokeOAuthToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	db := controllers.db
+
+	client, err := authenticateClient(db, r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		oauthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		oauthError(w, http.StatusBadRequest, "invalid_request", models.FieldisEmptyMessage("token"))
+		return
+	}
+
+	if err = authentication.RevokeClientToken(r.Context(), db, token, client.ClientID); err != nil {
+		oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// FindOAuthGrants gets the OAuth clients the user authorized, with the scopes granted to each one
+func (controllers *Controllers) FindOAuthGrants(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenUserID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userID != tokenUserID {
+		templates.Error(w, http.StatusForbidden, errors.New("its not possible to see other users OAuth grants"))
+		return
+	}
+
+	db := controllers.db
+
+	oauthGrantsRepository := repositories.NewOAuthGrantsRepository(db)
+	grants, err := oauthGrantsRepository.SearchByUser(r.Context(), userID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, grants)
+}
+
+// DeleteOAuthGrant withdraws the authorization given by the user to an OAuth client, ending its sessions
+func (controllers *Controllers) DeleteOAuthGrant(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenUserID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userID != tokenUserID {
+		templates.Error(w, http.StatusForbidden, errors.New("its not possible to delete other users OAuth grants"))
+		return
+	}
+
+	db := controllers.db
+
+	oauthGrantsRepository := repositories.NewOAuthGrantsRepository(db)
+	deleted, err := oauthGrantsRepository.Delete(r.Context(), userID, params["clientId"])
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !deleted {
+		templates.Error(w, http.StatusNotFound, errors.New("OAuth grant not found"))
+		return
+	}
+
+	if err = authentication.RevokeClientSessions(r.Context(), db, params["clientId"], userID); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
+
+var errInvalidAuthorizationCode = errors.New("invalid authorization code")
+
+// checkAuthorizationRequest validates an authorization request, returning its client and scopes. An error means
+// the client or the redirect URI can't be trusted, while an OAuth error can be sent back to the client
+func checkAuthorizationRequest(ctx context.Context, db *sql.DB, authorizationRequest models.OAuthAuthorizationRequest) (client models.OAuthClient, scopes []string, oauthErr *models.OAuthError, err error) {
+	oauthClientsRepository := repositories.NewOAuthClientsRepository(db)
+	if client, err = oauthClientsRepository.SearchByClientID(ctx, authorizationRequest.ClientID); err != nil {
+		return
+	}
+
+	if client.ID == 0 {
+		err = errors.New("unknown OAuth client")
+		return
+	}
+
+	if !client.AllowsRedirectURI(authorizationRequest.RedirectURI) {
+		err = errors.New("the redirect URI wasn't registered by the client")
+		return
+	}
+
+	if authorizationRequest.ResponseType != "code" {
+		oauthErr = &models.OAuthError{Error: "unsupported_response_type", ErrorDescription: "the response type must be code"}
+		return
+	}
+
+	if authorizationRequest.CodeChallengeMethod != "S256" || len(authorizationRequest.CodeChallenge) != 43 {
+		oauthErr = &models.OAuthError{Error: "invalid_request", ErrorDescription: "a S256 code challenge is required"}
+		return
+	}
+
+	for _, scope := range strings.Fields(authorizationRequest.Scope) {
+		if !authentication.ValidScope(scope) {
+			oauthErr = &models.OAuthError{Error: "invalid_scope", ErrorDescription: fmt.Sprintf("unknown scope %q", scope)}
+			return
+		}
+
+		if !containsScope(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if len(scopes) == 0 {
+		oauthErr = &models.OAuthError{Error: "invalid_scope", ErrorDescription: "at least one scope is required"}
+	}
+
+	return
+}
+
+// saveGrant adds the scopes to the ones the user already granted to the client
+func saveGrant(ctx context.Context, db *sql.DB, userID uint64, clientID string, scopes []string) error {
+	oauthGrantsRepository := repositories.NewOAuthGrantsRepository(db)
+	grant, found, err := oauthGrantsRepository.Search(ctx, userID, clientID)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return oauthGrantsRepository.Create(ctx, models.OAuthGrant{UserID: userID, ClientID: clientID, Scopes: scopes})
+	}
+
+	for _, scope := range scopes {
+		if !containsScope(grant.Scopes, scope) {
+			grant.Scopes = append(grant.Scopes, scope)
+		}
+	}
+
+	return oauthGrantsRepository.UpdateScopes(ctx, grant)
+}
+
+// exchangeAuthorizationCode starts a session of the client from an authorization code. Rejected codes
+// wrap errInvalidAuthorizationCode
+func exchangeAuthorizationCode(db *sql.DB, r *http.Request, client models.OAuthClient) (session models.Session, authenticationData models.AuthenticationData, err error) {
+	codesRepository := repositories.NewOAuthAuthorizationCodesRepository(db)
+	code, err := codesRepository.SearchByHash(r.Context(), security.HashToken(r.PostForm.Get("code")))
+	if err != nil {
+		return
+	}
+
+	if code.ID == 0 || code.Expired() || code.ClientID != client.ClientID {
+		err = errInvalidAuthorizationCode
+		return
+	}
+
+	// A code used twice may have been intercepted, so the session it started can't be trusted either
+	if code.UsedAt != nil {
+		if code.SessionID != nil {
+			if _, err = authentication.RevokeSession(r.Context(), db, code.UserID, *code.SessionID); err != nil {
+				return
+			}
+		}
+
+		err = fmt.Errorf("%w: the code was already used", errInvalidAuthorizationCode)
+		return
+	}
+
+	if r.PostForm.Get("redirect_uri") != code.RedirectURI {
+		err = fmt.Errorf("%w: the redirect URI doesn't match the authorization request", errInvalidAuthorizationCode)
+		return
+	}
+
+	if !security.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		err = fmt.Errorf("%w: the code verifier doesn't match the code challenge", errInvalidAuthorizationCode)
+		return
+	}
+
+	session = models.Session{UserID: code.UserID, ClientID: client.ClientID, Scopes: code.Scopes}
+	if session.ID, err = security.GenerateToken(16); err != nil {
+		return
+	}
+
+	// The code is only spent when its session starts
+	err = database.Transaction(r.Context(), db, func(tx *sql.Tx) (err error) {
+		codesRepository := repositories.NewOAuthAuthorizationCodesRepository(tx)
+		used, err := codesRepository.Use(r.Context(), code.ID, session.ID)
+		if err != nil {
+			return
+		}
+
+		if !used {
+			return fmt.Errorf("%w: the code was already used", errInvalidAuthorizationCode)
+		}
+
+		authenticationData, err = startClientSession(tx, r, session)
+		return
+	})
+	return
+}
+
+// authenticateClient finds the client of a token or revocation request, from HTTP Basic or from the form.
+// Confidential clients must send their secret, while public clients rely on PKCE alone
+func authenticateClient(db *sql.DB, r *http.Request) (client models.OAuthClient, err error) {
+	clientID, clientSecret, basicAuth := r.BasicAuth()
+	if !basicAuth {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	oauthClientsRepository := repositories.NewOAuthClientsRepository(db)
+	if client, err = oauthClientsRepository.SearchByClientID(r.Context(), clientID); err != nil {
+		return
+	}
+
+	if client.ID == 0 {
+		err = errors.New("unknown OAuth client")
+		return
+	}
+
+	if client.Confidential {
+		secretHash := security.HashToken(clientSecret)
+		if clientSecret == "" || subtle.ConstantTimeCompare([]byte(secretHash), []byte(client.SecretHash)) != 1 {
+			err = errors.New("invalid client secret")
+		}
+	}
+
+	return
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, existingScope := range scopes {
+		if existingScope == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withQuery adds the values to the query of the URI, keeping the ones it already has
+func withQuery(uri string, values url.Values) string {
+	parsedURI, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+
+	query := parsedURI.Query()
+	for key := range values {
+		query.Set(key, values.Get(key))
+	}
+	parsedURI.RawQuery = query.Encode()
+
+	return parsedURI.String()
+}
+
+func oauthError(w http.ResponseWriter, statusCode int, code, description string) {
+	templates.JSON(w, statusCode, models.OAuthError{Error: code, ErrorDescription: description})
+}
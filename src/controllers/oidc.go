@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"api/src/config"
+	"api/src/database"
+	"api/src/models"
+	"api/src/oidc"
+	"api/src/repositories"
+	"api/src/security"
+	"api/src/templates"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+)
+
+// OIDCProviders lists the OpenID Connect providers users can sign in with
+func (controllers *Controllers) OIDCProviders(w http.ResponseWriter, r *http.Request) {
+	templates.JSON(w, http.StatusOK, models.OIDCProviders{Providers: oidc.Names()})
+}
+
+// OIDCLogin starts a login on an OpenID Connect provider, redirecting the user to it
+func (controllers *Controllers) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	providerName := mux.Vars(r)["provider"]
+	provider, found := oidc.Providers[providerName]
+	if !found {
+		templates.Error(w, http.StatusNotFound, errors.New("unknown OpenID Connect provider"))
+		return
+	}
+
+	state, err := security.GenerateToken(32)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	nonce, err := security.GenerateToken(32)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	codeVerifier, err := security.GenerateToken(32)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	authorizationURL, err := provider.AuthorizationURL(state, nonce, security.PKCEChallenge(codeVerifier))
+	if err != nil {
+		templates.Error(w, http.StatusBadGateway, err)
+		return
+	}
+
+	db := controllers.db
+
+	oidcStatesRepository := repositories.NewOIDCStatesRepository(db)
+	if err = oidcStatesRepository.Create(r.Context(), models.OIDCState{
+		StateHash:    security.HashToken(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(config.OIDCStateDuration),
+	}); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = oidcStatesRepository.DeleteExpired(r.Context()); err != nil {
+		log.Printf("deleting the expired OpenID Connect states: %v", err)
+	}
+
+	http.Redirect(w, r, authorizationURL, http.StatusFound)
+}
+
+// OIDCCallback finishes a login on an OpenID Connect provider. The user is found by its account on the provider,
+// linked by a verified email to an existing user or created on the first login
+func (controllers *Controllers) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	providerName := mux.Vars(r)["provider"]
+	provider, found := oidc.Providers[providerName]
+	if !found {
+		templates.Error(w, http.StatusNotFound, errors.New("unknown OpenID Connect provider"))
+		return
+	}
+
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		templates.Error(w, http.StatusUnauthorized, fmt.Errorf("the provider refused the login: %s %s", providerError, query.Get("error_description")))
+		return
+	}
+
+	db := controllers.db
+
+	oidcStatesRepository := repositories.NewOIDCStatesRepository(db)
+	state, found, err := oidcStatesRepository.Consume(r.Context(), security.HashToken(query.Get("state")))
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !found || state.Expired() || state.Provider != providerName {
+		templates.Error(w, http.StatusBadRequest, errors.New("invalid or expired login, please start it again"))
+		return
+	}
+
+	identity, err := provider.Exchange(query.Get("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	userIdentitiesRepository := repositories.NewUserIdentitiesRepository(db)
+	userID, err := userIdentitiesRepository.SearchUserID(r.Context(), providerName, identity.Subject)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if userID != 0 {
+		controllers.finishLogin(w, r, userID)
+		return
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		templates.Error(w, http.StatusForbidden, errors.New("the provider didn't verify the email of the account"))
+		return
+	}
+
+	emailOwner, err := controllers.users.SearchByEmail(r.Context(), identity.Email)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// An unverified email may have been registered by someone else, so it isn't enough to link the accounts
+	if emailOwner.ID != 0 && emailOwner.EmailVerifiedAt == nil {
+		templates.Error(w, http.StatusConflict, errors.New("an user with this email exists, but the email wasn't verified yet"))
+		return
+	}
+
+	var newUser models.User
+	if emailOwner.ID == 0 {
+		// The email of a deleted user is kept until it is purged, so an admin can still restore the account
+		deletedOwner, err := controllers.users.EmailExists(r.Context(), identity.Email)
+		if err != nil {
+			templates.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if deletedOwner {
+			templates.Error(w, http.StatusForbidden, errors.New("the account with this email was deleted"))
+			return
+		}
+
+		if newUser, err = controllers.newOIDCUser(r.Context(), identity); err != nil {
+			templates.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	// A new user is only kept with the identity that logs it in, or it couldn't login again
+	if err = database.Transaction(r.Context(), db, func(tx *sql.Tx) (err error) {
+		userID = emailOwner.ID
+		if userID == 0 {
+			userRepository := controllers.users.WithTx(tx)
+			if userID, err = userRepository.Create(r.Context(), newUser); err != nil {
+				return
+			}
+
+			if err = userRepository.VerifyEmail(r.Context(), userID, newUser.Email); err != nil {
+				return
+			}
+		}
+
+		userIdentitiesRepository := repositories.NewUserIdentitiesRepository(tx)
+		return userIdentitiesRepository.Create(r.Context(), models.UserIdentity{
+			UserID:   userID,
+			Provider: providerName,
+			Subject:  identity.Subject,
+		})
+	}); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	controllers.finishLogin(w, r, userID)
+}
+
+// newOIDCUser prepares the user of a first login on a provider, with a random password that can be replaced
+// through the password reset. Its email gets verified when it is created
+func (controllers *Controllers) newOIDCUser(ctx context.Context, identity oidc.Identity) (user models.User, err error) {
+	emailName, _, _ := strings.Cut(identity.Email, "@")
+
+	password, err := security.GenerateToken(32)
+	if err != nil {
+		return
+	}
+
+	user = models.User{
+		Name:     identity.Name,
+		Email:    identity.Email,
+		Password: password,
+	}
+	if user.Name == "" {
+		user.Name = emailName
+	}
+	if len(user.Name) > 50 {
+		user.Name = user.Name[:50]
+	}
+
+	if user.Nick, err = controllers.availableNick(ctx, identity.PreferredUsername, emailName); err != nil {
+		return
+	}
+
+	// The random password skips the strength policy, since it could contain a piece of the name by chance
+	if err = user.Prepare(models.EDIT); err != nil {
+		return
+	}
+
+	hashedPassword, err := security.Hash(user.Password)
+	if err != nil {
+		return
+	}
+	user.Password = string(hashedPassword)
+
+	return
+}
+
+// availableNick finds an unused nick from the first usable candidate, adding random digits when it is taken
+func (controllers *Controllers) availableNick(ctx context.Context, candidates ...string) (string, error) {
+	base := ""
+	for _, candidate := range candidates {
+		if base = sanitizeNick(candidate); base != "" {
+			break
+		}
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	nick := base
+	for attempt := 0; attempt < 10; attempt++ {
+		exists, err := controllers.users.NickExists(ctx, nick)
+		if err != nil {
+			return "", err
+		}
+
+		if !exists {
+			return nick, nil
+		}
+
+		nick = fmt.Sprintf("%s%04d", base, rand.Intn(10000))
+	}
+
+	return "", errors.New("couldn't find an available nick")
+}
+
+func sanitizeNick(nick string) string {
+	nick = strings.Map(func(character rune) rune {
+		if character < unicode.MaxASCII && (unicode.IsLetter(character) || unicode.IsDigit(character) || strings.ContainsRune("_.-", character)) {
+			return unicode.ToLower(character)
+		}
+		return -1
+	}, nick)
+
+	if len(nick) > 45 {
+		nick = nick[:45]
+	}
+
+	return nick
+}
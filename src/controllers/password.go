@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/mail"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/security"
+	"api/src/templates"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ForgotPassword sends a password reset link to the email, if it belongs to an user.
+// The response is the same either way, so it can't be used to discover who has an account
+func (controllers *Controllers) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		templates.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var passwordForgot models.PasswordForgot
+	if err = json.Unmarshal(requestBody, &passwordForgot); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	email := strings.TrimSpace(passwordForgot.Email)
+	if email == "" {
+		templates.Error(w, http.StatusBadRequest, errors.New(models.FieldisEmptyMessage("email")))
+		return
+	}
+
+	db := controllers.db
+
+	user, err := controllers.users.SearchByEmail(r.Context(), email)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if user.ID != 0 {
+		token, err := security.GenerateToken(32)
+		if err != nil {
+			templates.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		passwordResetsRepository := repositories.NewPasswordResetsRepository(db)
+		if err = passwordResetsRepository.Create(r.Context(), models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: security.HashToken(token),
+			ExpiresAt: time.Now().Add(config.PasswordResetDuration),
+		}); err != nil {
+			templates.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// Sent to the address on file, which may be written differently than the one asked for. In background, so
+		// the response time doesn't tell if the email has an account
+		go func() {
+			if err := mail.Send(passwordResetMessage(user.Email, token)); err != nil {
+				log.Printf("sending the password reset email: %v", err)
+			}
+		}()
+	}
+
+	templates.JSON(w, http.StatusAccepted, nil)
+}
+
+// ResetPassword chooses a new password with a token received by email, signing the user out everywhere
+func (controllers *Controllers) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		templates.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var passwordReset models.PasswordReset
+	if err = json.Unmarshal(requestBody, &passwordReset); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if passwordReset.Token == "" {
+		templates.Error(w, http.StatusBadRequest, errors.New(models.FieldisEmptyMessage("token")))
+		return
+	}
+
+	if passwordReset.Password == "" {
+		templates.Error(w, http.StatusBadRequest, errors.New(models.FieldisEmptyMessage("password")))
+		return
+	}
+
+	db := controllers.db
+
+	passwordResetsRepository := repositories.NewPasswordResetsRepository(db)
+	passwordResetToken, err := passwordResetsRepository.SearchByHash(r.Context(), security.HashToken(passwordReset.Token))
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	invalidTokenError := errors.New("the password reset token is invalid or expired")
+	if passwordResetToken.ID == 0 || passwordResetToken.UsedAt != nil || passwordResetToken.Expired() {
+		templates.Error(w, http.StatusBadRequest, invalidTokenError)
+		return
+	}
+
+	user, err := controllers.users.SerachByID(r.Context(), passwordResetToken.UserID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if user.ID == 0 {
+		templates.Error(w, http.StatusBadRequest, invalidTokenError)
+		return
+	}
+
+	if err = security.CheckPasswordStrength(passwordReset.Password, user.Name, user.Nick, user.Email); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	hashedPassword, err := security.Hash(passwordReset.Password)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// The token is only spent when the password changes with it, signing the user out everywhere
+	err = authentication.RevokeUserTokensWith(r.Context(), db, passwordResetToken.UserID, func(tx *sql.Tx) error {
+		passwordResetsRepository := repositories.NewPasswordResetsRepository(tx)
+		used, err := passwordResetsRepository.Use(r.Context(), passwordResetToken.ID)
+		if err != nil {
+			return err
+		}
+
+		if !used {
+			return invalidTokenError
+		}
+
+		// A deleted user keeps its reset tokens until it is purged, but they can't change its password
+		userRepository := controllers.users.WithTx(tx)
+		updated, err := userRepository.UpdatePassword(r.Context(), passwordResetToken.UserID, string(hashedPassword))
+		if err != nil {
+			return err
+		}
+
+		if !updated {
+			return invalidTokenError
+		}
+
+		return passwordResetsRepository.UseAllFromUser(r.Context(), passwordResetToken.UserID)
+	})
+	if errors.Is(err, invalidTokenError) {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
+
+func passwordResetMessage(email, token string) mail.Message {
+	link := fmt.Sprintf("%s/reset-password?token=%s", config.AppURL, url.QueryEscape(token))
+
+	return mail.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password of your account.\n\n"+
+				"To choose a new password, open the link below in the next %s:\n\n%s\n\n"+
+				"If it wasn't you, just ignore this email.",
+			config.PasswordResetDuration,
+			link,
+		),
+	}
+}
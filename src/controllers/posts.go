@@ -2,22 +2,22 @@ package controllers
 
 import (
 	"api/src/authentication"
-	"api/src/database"
+	"api/src/config"
 	"api/src/models"
-	"api/src/repositories"
 	"api/src/templates"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 // CreatePost creates a new post on the database
-func CreatePost(w http.ResponseWriter, r *http.Request) {
-	userID, err := authentication.ExtractUserID(r)
+func (controllers *Controllers) CreatePost(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.UserIDFromRequest(r)
 	if err != nil {
 		templates.Error(w, http.StatusUnauthorized, err)
 		return
@@ -42,40 +42,40 @@ func CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := database.Connect()
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
-		return
+	if config.RequireVerifiedEmailToPost {
+		user, err := controllers.users.SerachByID(r.Context(), userID)
+		if err != nil {
+			templates.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if user.EmailVerifiedAt == nil {
+			templates.Error(w, http.StatusForbidden, errors.New("verify your email before posting"))
+			return
+		}
 	}
-	defer db.Close()
 
-	postRepository := repositories.NewPostRepository(db)
-	post.ID, err = postRepository.CreatePost(post)
+	post.ID, err = controllers.posts.CreatePost(r.Context(), post)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	post.Version = 1
+	w.Header().Set("ETag", etag(post.Version))
+
 	templates.JSON(w, http.StatusCreated, post)
 }
 
 // FindPosts find all posts in the database
-func FindPosts(w http.ResponseWriter, r *http.Request) {
-	userID, err := authentication.ExtractUserID(r)
+func (controllers *Controllers) FindPosts(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.UserIDFromRequest(r)
 	if err != nil {
 		templates.Error(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	db, err := database.Connect()
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer db.Close()
-
-	postRepository := repositories.NewPostRepository(db)
-	posts, err := postRepository.Search(userID)
+	posts, err := controllers.posts.Search(r.Context(), userID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
@@ -85,7 +85,7 @@ func FindPosts(w http.ResponseWriter, r *http.Request) {
 }
 
 // FindPost find a single post in the database
-func FindPost(w http.ResponseWriter, r *http.Request) {
+func (controllers *Controllers) FindPost(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	postID, err := strconv.ParseUint(params["postId"], 10, 64)
 	if err != nil {
@@ -93,26 +93,22 @@ func FindPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := database.Connect()
+	post, err := controllers.posts.SearchByID(r.Context(), postID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	defer db.Close()
 
-	postRepository := repositories.NewPostRepository(db)
-	post, err := postRepository.SearchByID(postID)
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
-		return
+	if post.ID != 0 {
+		w.Header().Set("ETag", etag(post.Version))
 	}
 
 	templates.JSON(w, http.StatusOK, post)
 }
 
 // UpdatePost update information of a single post
-func UpdatePost(w http.ResponseWriter, r *http.Request) {
-	userID, err := authentication.ExtractUserID(r)
+func (controllers *Controllers) UpdatePost(w http.ResponseWriter, r *http.Request) {
+	claims, err := authentication.ClaimsFromRequest(r)
 	if err != nil {
 		templates.Error(w, http.StatusUnauthorized, err)
 		return
@@ -125,25 +121,28 @@ func UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := database.Connect()
+	postSavedOnDB, err := controllers.posts.SearchByID(r.Context(), postID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	defer db.Close()
 
-	postRepository := repositories.NewPostRepository(db)
-	postSavedOnDB, err := postRepository.SearchByID(postID)
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
+	if postSavedOnDB.ID == 0 {
+		templates.Error(w, http.StatusNotFound, errors.New("post not found"))
 		return
 	}
 
-	if postSavedOnDB.AuthorID != userID {
+	if postSavedOnDB.AuthorID != claims.UserID && !claims.Can(authentication.PermissionPostsUpdateAny) {
 		templates.Error(w, http.StatusForbidden, errors.New("its not possible to update others user's posts"))
 		return
 	}
 
+	if err = checkIfMatch(r, postSavedOnDB.Version); err != nil {
+		w.Header().Set("ETag", etag(postSavedOnDB.Version))
+		templates.Error(w, preconditionStatus(err), err)
+		return
+	}
+
 	requestBody, err := io.ReadAll(r.Body)
 	if err != nil {
 		templates.Error(w, http.StatusUnprocessableEntity, err)
@@ -161,17 +160,25 @@ func UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = postRepository.UpdatePost(postID, post); err != nil {
+	updated, err := controllers.posts.UpdatePost(r.Context(), postID, postSavedOnDB.Version, post)
+	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	// Someone else changed it between the read and the update
+	if !updated {
+		templates.Error(w, http.StatusPreconditionFailed, errVersionConflict)
+		return
+	}
+
+	w.Header().Set("ETag", etag(postSavedOnDB.Version+1))
 	templates.JSON(w, http.StatusNoContent, nil)
 }
 
-// DeletePost delete a single post from the database
-func DeletePost(w http.ResponseWriter, r *http.Request) {
-	userID, err := authentication.ExtractUserID(r)
+// DeletePost delete a single post from the database. It can be restored for DELETED_RETENTION
+func (controllers *Controllers) DeletePost(w http.ResponseWriter, r *http.Request) {
+	claims, err := authentication.ClaimsFromRequest(r)
 	if err != nil {
 		templates.Error(w, http.StatusUnauthorized, err)
 		return
@@ -184,26 +191,23 @@ func DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := database.Connect()
+	postSavedOnDB, err := controllers.posts.SearchByID(r.Context(), postID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	defer db.Close()
 
-	postRepository := repositories.NewPostRepository(db)
-	postSavedOnDB, err := postRepository.SearchByID(postID)
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
+	if postSavedOnDB.ID == 0 {
+		templates.Error(w, http.StatusNotFound, errors.New("post not found"))
 		return
 	}
 
-	if postSavedOnDB.AuthorID != userID {
+	if postSavedOnDB.AuthorID != claims.UserID && !claims.Can(authentication.PermissionPostsDeleteAny) {
 		templates.Error(w, http.StatusForbidden, errors.New("its not possible to delete others user's posts"))
 		return
 	}
 
-	if err = postRepository.DeletePost(postID); err != nil {
+	if err = controllers.posts.DeletePost(r.Context(), postID); err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -211,50 +215,79 @@ func DeletePost(w http.ResponseWriter, r *http.Request) {
 	templates.JSON(w, http.StatusNoContent, nil)
 }
 
-// SeachPostsByUser gets all posts from an user
-func SeachPostsByUser(w http.ResponseWriter, r *http.Request) {
+// RestorePost brings back a deleted post while it is within DELETED_RETENTION
+func (controllers *Controllers) RestorePost(w http.ResponseWriter, r *http.Request) {
+	claims, err := authentication.ClaimsFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
 	params := mux.Vars(r)
-	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	postID, err := strconv.ParseUint(params["postId"], 10, 64)
 	if err != nil {
 		templates.Error(w, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := database.Connect()
+	deletedPost, err := controllers.posts.SearchDeletedPost(r.Context(), postID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	defer db.Close()
 
-	postRepository := repositories.NewPostRepository(db)
-	posts, err := postRepository.SearchPostsByUser(userID)
+	if deletedPost.ID == 0 {
+		templates.Error(w, http.StatusNotFound, errors.New("deleted post not found"))
+		return
+	}
+
+	if deletedPost.AuthorID != claims.UserID && !claims.Can(authentication.PermissionPostsDeleteAny) {
+		templates.Error(w, http.StatusForbidden, errors.New("its not possible to restore others user's posts"))
+		return
+	}
+
+	restored, err := controllers.posts.RestorePost(r.Context(), postID, time.Now().Add(-config.DeletedRetention))
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	templates.JSON(w, http.StatusOK, posts)
+	if !restored {
+		templates.Error(w, http.StatusGone, errors.New("the post was deleted too long ago to be restored"))
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
 }
 
-// LikePost add a like on the post
-func LikePost(w http.ResponseWriter, r *http.Request) {
+// SeachPostsByUser gets all posts from an user
+func (controllers *Controllers) SeachPostsByUser(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
-	postID, err := strconv.ParseUint(params["postId"], 10, 64)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
 	if err != nil {
 		templates.Error(w, http.StatusBadRequest, err)
 		return
 	}
 
-	db, err := database.Connect()
+	posts, err := controllers.posts.SearchPostsByUser(r.Context(), userID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	defer db.Close()
 
-	postRepository := repositories.NewPostRepository(db)
-	if err = postRepository.Like(postID); err != nil {
+	templates.JSON(w, http.StatusOK, posts)
+}
+
+// LikePost add a like on the post
+func (controllers *Controllers) LikePost(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	postID, err := strconv.ParseUint(params["postId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = controllers.posts.Like(r.Context(), postID); err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -263,7 +296,7 @@ func LikePost(w http.ResponseWriter, r *http.Request) {
 }
 
 // UnLikePost removes a like from the post
-func UnLikePost(w http.ResponseWriter, r *http.Request) {
+func (controllers *Controllers) UnLikePost(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	postID, err := strconv.ParseUint(params["postId"], 10, 64)
 	if err != nil {
@@ -271,15 +304,7 @@ func UnLikePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := database.Connect()
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer db.Close()
-
-	postRepository := repositories.NewPostRepository(db)
-	if err = postRepository.UnLike(postID); err != nil {
+	if err = controllers.posts.UnLike(r.Context(), postID); err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -0,0 +1,128 @@
+package controllers_test
+
+import (
+	"api/src/authentication"
+	"api/src/controllers"
+	"api/src/models"
+	"api/src/repositories/memory"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestUpdatePost(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		claims     *authentication.Claims
+		postID     string
+		ifMatch    string
+		body       string
+		wantStatus int
+		wantETag   string
+		wantTitle  string
+	}{
+		{"without authentication", nil, "1", `"1"`, `{"title":"Edited","content":"Edited"}`, http.StatusUnauthorized, "", "Hello"},
+		{"a post that doesn't exist", author(), "2", `"1"`, `{"title":"Edited","content":"Edited"}`, http.StatusNotFound, "", "Hello"},
+		{"the post of another user", stranger(), "1", `"1"`, `{"title":"Edited","content":"Edited"}`, http.StatusForbidden, "", "Hello"},
+		{"without If-Match", author(), "1", "", `{"title":"Edited","content":"Edited"}`, http.StatusPreconditionRequired, `"1"`, "Hello"},
+		{"at a stale version", author(), "1", `"0"`, `{"title":"Edited","content":"Edited"}`, http.StatusPreconditionFailed, `"1"`, "Hello"},
+		{"without a title", author(), "1", `"1"`, `{"content":"Edited"}`, http.StatusBadRequest, "", "Hello"},
+		{"by its author", author(), "1", `"1"`, `{"title":"Edited","content":"Edited"}`, http.StatusNoContent, `"2"`, "Edited"},
+		{"at any version", author(), "1", "*", `{"title":"Edited","content":"Edited"}`, http.StatusNoContent, `"2"`, "Edited"},
+		{"by a moderator", moderator(), "1", `"1"`, `{"title":"Moderated","content":"Edited"}`, http.StatusNoContent, `"2"`, "Moderated"},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			store := memory.New()
+			handlers := controllers.New(nil, store.Users(), store.Posts())
+
+			authorID, err := store.Users().Create(ctx, models.User{Name: "Alice", Nick: "alice", Email: "alice@example.com", Password: "hash"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			postID, err := store.Posts().CreatePost(ctx, models.Post{Title: "Hello", Content: "Hello!", AuthorID: authorID})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodPut, "/posts/"+testCase.postID, strings.NewReader(testCase.body))
+			r = mux.SetURLVars(r, map[string]string{"postId": testCase.postID})
+			if testCase.ifMatch != "" {
+				r.Header.Set("If-Match", testCase.ifMatch)
+			}
+
+			if testCase.claims != nil {
+				r = authentication.WithClaims(r, testCase.claims)
+			}
+
+			w := httptest.NewRecorder()
+			handlers.UpdatePost(w, r)
+
+			if w.Code != testCase.wantStatus {
+				t.Errorf("got status %d, want %d: %s", w.Code, testCase.wantStatus, w.Body)
+			}
+
+			if etag := w.Header().Get("ETag"); etag != testCase.wantETag {
+				t.Errorf("got ETag %s, want %s", etag, testCase.wantETag)
+			}
+
+			post, err := store.Posts().SearchByID(ctx, postID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if post.Title != testCase.wantTitle {
+				t.Errorf("got title %q, want %q", post.Title, testCase.wantTitle)
+			}
+		})
+	}
+}
+
+func TestFindUser(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := memory.New()
+	handlers := controllers.New(nil, store.Users(), store.Posts())
+
+	userID, err := store.Users().Create(ctx, models.User{Name: "Alice", Nick: "alice", Email: "alice@example.com", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/users/1", nil), map[string]string{"userId": strconv.FormatUint(userID, 10)})
+	w := httptest.NewRecorder()
+	handlers.FindUser(w, r)
+
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"1"` {
+		t.Errorf("got status %d and ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	if body := w.Body.String(); !strings.Contains(body, `"alice@example.com"`) || strings.Contains(body, "hash") {
+		t.Errorf("got %s", body)
+	}
+}
+
+func author() *authentication.Claims {
+	return &authentication.Claims{UserID: 1, Roles: []string{"user"}}
+}
+
+func stranger() *authentication.Claims {
+	return &authentication.Claims{UserID: 2, Roles: []string{"user"}}
+}
+
+func moderator() *authentication.Claims {
+	return &authentication.Claims{UserID: 3, Roles: []string{"moderator"}, Permissions: []string{authentication.PermissionPostsUpdateAny}}
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/repositories"
+	"api/src/templates"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// FindSessions gets the active sessions of the user, telling which one made the request
+func (controllers *Controllers) FindSessions(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	claims, err := authentication.ClaimsFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userID != claims.UserID {
+		templates.Error(w, http.StatusForbidden, errors.New("its not possible to see other users sessions"))
+		return
+	}
+
+	db := controllers.db
+
+	sessionsRepository := repositories.NewSessionsRepository(db)
+	sessions, err := sessionsRepository.SearchActiveByUser(r.Context(), userID, time.Now().Add(-config.RefreshTokenDuration))
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == claims.SessionID
+	}
+
+	templates.JSON(w, http.StatusOK, sessions)
+}
+
+// DeleteSession ends a session of the user, signing out the device that holds it
+func (controllers *Controllers) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenUserID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userID != tokenUserID {
+		templates.Error(w, http.StatusForbidden, errors.New("its not possible to end other users sessions"))
+		return
+	}
+
+	revoked, err := authentication.RevokeSession(r.Context(), controllers.db, userID, params["sessionId"])
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !revoked {
+		templates.Error(w, http.StatusNotFound, errors.New("session not found"))
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,265 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/database"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/security"
+	"api/src/templates"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const recoveryCodesAmount = 10
+
+// EnrollTOTP starts the two-factor authentication enrollment, returning the secret for the authenticator app
+func (controllers *Controllers) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenUserID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userID != tokenUserID {
+		templates.Error(w, http.StatusForbidden, errors.New("its not possible to change other users two-factor authentication"))
+		return
+	}
+
+	totp, err := controllers.users.SearchTOTP(r.Context(), userID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if totp.Enabled {
+		templates.Error(w, http.StatusConflict, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	user, err := controllers.users.SerachByID(r.Context(), userID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = controllers.users.SaveTOTPSecret(r.Context(), userID, secret); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, models.TOTPEnrollment{
+		Secret: secret,
+		URI:    security.TOTPURI(config.TOTPIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP enables the two-factor authentication once the user proves the authenticator works
+func (controllers *Controllers) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenUserID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userID != tokenUserID {
+		templates.Error(w, http.StatusForbidden, errors.New("its not possible to change other users two-factor authentication"))
+		return
+	}
+
+	bodyRequest, err := io.ReadAll(r.Body)
+	if err != nil {
+		templates.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var secondFactor models.SecondFactor
+	if err = json.Unmarshal(bodyRequest, &secondFactor); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	db := controllers.db
+
+	totp, err := controllers.users.SearchTOTP(r.Context(), userID)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if totp.Enabled {
+		templates.Error(w, http.StatusConflict, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	if totp.Secret == "" {
+		templates.Error(w, http.StatusBadRequest, errors.New("the two-factor authentication enrollment wasn't started"))
+		return
+	}
+
+	step, valid := security.ValidateTOTP(totp.Secret, secondFactor.Code, time.Now())
+	if !valid {
+		templates.Error(w, http.StatusUnauthorized, errors.New("invalid two-factor authentication code"))
+		return
+	}
+
+	recoveryCodes, codeHashes, err := generateRecoveryCodes()
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Two-factor authentication is only on when the user has the recovery codes to get past it
+	if err = database.Transaction(r.Context(), db, func(tx *sql.Tx) (err error) {
+		userRepository := controllers.users.WithTx(tx)
+		if _, err = userRepository.UseTOTPStep(r.Context(), userID, step); err != nil {
+			return
+		}
+
+		recoveryCodesRepository := repositories.NewRecoveryCodesRepository(tx)
+		if err = recoveryCodesRepository.Replace(r.Context(), userID, codeHashes); err != nil {
+			return
+		}
+
+		return userRepository.EnableTOTP(r.Context(), userID)
+	}); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusOK, models.RecoveryCodes{RecoveryCodes: recoveryCodes})
+}
+
+// DisableTOTP turns the two-factor authentication off, requiring a code or a recovery code
+func (controllers *Controllers) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["userId"], 10, 64)
+	if err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tokenUserID, err := authentication.UserIDFromRequest(r)
+	if err != nil {
+		templates.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if userID != tokenUserID {
+		templates.Error(w, http.StatusForbidden, errors.New("its not possible to change other users two-factor authentication"))
+		return
+	}
+
+	bodyRequest, err := io.ReadAll(r.Body)
+	if err != nil {
+		templates.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var secondFactor models.SecondFactor
+	if err = json.Unmarshal(bodyRequest, &secondFactor); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	db := controllers.db
+
+	verified, err := controllers.verifySecondFactor(r.Context(), userID, secondFactor)
+	if err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !verified {
+		templates.Error(w, http.StatusUnauthorized, errors.New("invalid two-factor authentication code"))
+		return
+	}
+
+	if err = database.Transaction(r.Context(), db, func(tx *sql.Tx) (err error) {
+		userRepository := controllers.users.WithTx(tx)
+		if err = userRepository.DisableTOTP(r.Context(), userID); err != nil {
+			return
+		}
+
+		recoveryCodesRepository := repositories.NewRecoveryCodesRepository(tx)
+		return recoveryCodesRepository.DeleteAllFromUser(r.Context(), userID)
+	}); err != nil {
+		templates.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	templates.JSON(w, http.StatusNoContent, nil)
+}
+
+// verifySecondFactor checks a TOTP code or a recovery code of the user, consuming it so it can't be used again
+func (controllers *Controllers) verifySecondFactor(ctx context.Context, userID uint64, secondFactor models.SecondFactor) (verified bool, err error) {
+	if secondFactor.Code != "" {
+		totp, err := controllers.users.SearchTOTP(ctx, userID)
+		if err != nil || !totp.Enabled {
+			return false, err
+		}
+
+		step, valid := security.ValidateTOTP(totp.Secret, secondFactor.Code, time.Now())
+		if !valid {
+			return false, nil
+		}
+
+		return controllers.users.UseTOTPStep(ctx, userID, step)
+	}
+
+	if secondFactor.RecoveryCode != "" {
+		code := strings.ToLower(strings.TrimSpace(secondFactor.RecoveryCode))
+
+		recoveryCodesRepository := repositories.NewRecoveryCodesRepository(controllers.db)
+		return recoveryCodesRepository.Use(ctx, userID, security.HashToken(code))
+	}
+
+	return false, nil
+}
+
+// generateRecoveryCodes creates new recovery codes, in plain text for the user and hashed for the database. They
+// are random like the tokens, so their SHA-256 is enough and lets a code be found by its hash, which the salted
+// password hashes of security.Hash wouldn't
+func generateRecoveryCodes() (recoveryCodes, codeHashes []string, err error) {
+	for i := 0; i < recoveryCodesAmount; i++ {
+		recoveryCode, err := security.GenerateRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		recoveryCodes = append(recoveryCodes, recoveryCode)
+		codeHashes = append(codeHashes, security.HashToken(recoveryCode))
+	}
+
+	return
+}
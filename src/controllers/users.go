@@ -2,14 +2,15 @@ package controllers
 
 import (
 	"api/src/authentication"
-	"api/src/database"
 	"api/src/models"
 	"api/src/repositories"
 	"api/src/security"
 	"api/src/templates"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -18,7 +19,7 @@ import (
 )
 
 // CreateUser creates a new user on database
-func CreateUser(w http.ResponseWriter, r *http.Request) {
+func (controllers *Controllers) CreateUser(w http.ResponseWriter, r *http.Request) {
 	bodyRequest, err := io.ReadAll(r.Body)
 	if err != nil {
 		templates.Error(w, http.StatusUnprocessableEntity, err)
@@ -36,36 +37,29 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := database.Connect()
+	db := controllers.db
+
+	user.ID, err = controllers.users.Create(r.Context(), user)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	defer db.Close()
 
-	userRepository := repositories.NewUserRepository(db)
-	user.ID, err = userRepository.Create(user)
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
-		return
+	if err = sendEmailVerification(r.Context(), db, user.ID, user.Email); err != nil {
+		log.Printf("creating the email verification of the user %d: %v", user.ID, err)
 	}
 
+	user.Version = 1
+	w.Header().Set("ETag", etag(user.Version))
+
 	templates.JSON(w, http.StatusCreated, user)
 }
 
 // FindUsers retrieve all users from the database
-func FindUsers(w http.ResponseWriter, r *http.Request) {
+func (controllers *Controllers) FindUsers(w http.ResponseWriter, r *http.Request) {
 	nameOrNick := strings.ToLower(r.URL.Query().Get("user"))
 
-	db, err := database.Connect()
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer db.Close()
-
-	userRepository := repositories.NewUserRepository(db)
-	users, err := userRepository.Search(nameOrNick)
+	users, err := controllers.users.Search(r.Context(), nameOrNick)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
@@ -75,7 +69,7 @@ func FindUsers(w http.ResponseWriter, r *http.Request) {
 }
 
 // FindUser retrieve one specified users from the database
-func FindUser(w http.ResponseWriter, r *http.Request) {
+func (controllers *Controllers) FindUser(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 
 	userID, err := strconv.ParseUint(params["userId"], 10, 64)
@@ -84,25 +78,21 @@ func FindUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := database.Connect()
+	user, err := controllers.users.SerachByID(r.Context(), userID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	defer db.Close()
 
-	userRepository := repositories.NewUserRepository(db)
-	user, err := userRepository.SerachByID(userID)
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
-		return
+	if user.ID != 0 {
+		w.Header().Set("ETag", etag(user.Version))
 	}
 
 	templates.JSON(w, http.StatusOK, user)
 }
 
 // UpdateUser updates one specified users from the database
-func UpdateUser(w http.ResponseWriter, r *http.Request) {
+func (controllers *Controllers) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	userID, err := strconv.ParseUint(params["userId"], 10, 64)
 	if err != nil {
@@ -110,13 +100,13 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenUserID, err := authentication.ExtractUserID(r)
+	claims, err := authentication.ClaimsFromRequest(r)
 	if err != nil {
 		templates.Error(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	if userID != tokenUserID {
+	if userID != claims.UserID && !claims.Can(authentication.PermissionUsersUpdateAny) {
 		templates.Error(w, http.StatusForbidden, errors.New("its not possible to update other users information, only your own"))
 		return
 	}
@@ -138,24 +128,69 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := database.Connect()
+	db := controllers.db
+
+	userSavedOnDB, err := controllers.users.SerachByID(r.Context(), userID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	defer db.Close()
 
-	userRepository := repositories.NewUserRepository(db)
-	if err = userRepository.Update(userID, user); err != nil {
+	if userSavedOnDB.ID == 0 {
+		templates.Error(w, http.StatusNotFound, errors.New("user not found"))
+		return
+	}
+
+	if err = checkIfMatch(r, userSavedOnDB.Version); err != nil {
+		w.Header().Set("ETag", etag(userSavedOnDB.Version))
+		templates.Error(w, preconditionStatus(err), err)
+		return
+	}
+
+	// The new email only replaces the current one after being verified
+	newEmail := user.Email
+	user.Email = userSavedOnDB.Email
+	emailChanged := !strings.EqualFold(newEmail, userSavedOnDB.Email)
+
+	if emailChanged {
+		// A deleted user still holds its email, so it can be restored
+		emailInUse, err := controllers.users.EmailExists(r.Context(), newEmail)
+		if err != nil {
+			templates.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if emailInUse {
+			templates.Error(w, http.StatusConflict, errors.New("the email is already in use by another user"))
+			return
+		}
+	}
+
+	updated, err := controllers.users.Update(r.Context(), userID, userSavedOnDB.Version, user)
+	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	// Someone else changed it between the read and the update
+	if !updated {
+		templates.Error(w, http.StatusPreconditionFailed, errVersionConflict)
+		return
+	}
+
+	if emailChanged {
+		if err = sendEmailVerification(r.Context(), db, userID, newEmail); err != nil {
+			templates.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	w.Header().Set("ETag", etag(userSavedOnDB.Version+1))
 	templates.JSON(w, http.StatusNoContent, nil)
 }
 
-// DeleteUser deletes one specified users from the database
-func DeleteUser(w http.ResponseWriter, r *http.Request) {
+// DeleteUser deletes one specified users from the database. It can be restored by an admin for DELETED_RETENTION
+func (controllers *Controllers) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 
 	userID, err := strconv.ParseUint(params["userId"], 10, 64)
@@ -164,26 +199,32 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenUserID, err := authentication.ExtractUserID(r)
+	claims, err := authentication.ClaimsFromRequest(r)
 	if err != nil {
 		templates.Error(w, http.StatusUnauthorized, err)
 		return
 	}
 
-	if userID != tokenUserID {
+	if userID != claims.UserID && !claims.Can(authentication.PermissionUsersDeleteAny) {
 		templates.Error(w, http.StatusForbidden, errors.New("its not possible to delete other users, only your own"))
 		return
 	}
 
-	db, err := database.Connect()
+	lastAdmin, err := isLastAdmin(r.Context(), repositories.NewRolesRepository(controllers.db), userID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	defer db.Close()
 
-	userRepository := repositories.NewUserRepository(db)
-	if err = userRepository.Delete(userID); err != nil {
+	if lastAdmin {
+		templates.Error(w, http.StatusConflict, errors.New("its not possible to delete the last admin"))
+		return
+	}
+
+	// A delete that fails doesn't leave the user signed out everywhere
+	if err = authentication.RevokeUserTokensWith(r.Context(), controllers.db, userID, func(tx *sql.Tx) error {
+		return controllers.users.WithTx(tx).Delete(r.Context(), userID)
+	}); err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -192,8 +233,8 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 }
 
 // FollowUser enables an user to follow another
-func FollowUser(w http.ResponseWriter, r *http.Request) {
-	followerID, err := authentication.ExtractUserID(r)
+func (controllers *Controllers) FollowUser(w http.ResponseWriter, r *http.Request) {
+	followerID, err := authentication.UserIDFromRequest(r)
 	if err != nil {
 		templates.Error(w, http.StatusUnauthorized, err)
 		return
@@ -211,15 +252,7 @@ func FollowUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := database.Connect()
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer db.Close()
-
-	userRepository := repositories.NewUserRepository(db)
-	if err = userRepository.FollowUser(userID, followerID); err != nil {
+	if err = controllers.users.FollowUser(r.Context(), userID, followerID); err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -228,8 +261,8 @@ func FollowUser(w http.ResponseWriter, r *http.Request) {
 }
 
 // UnFollowUser enables an user to follow another
-func UnFollowUser(w http.ResponseWriter, r *http.Request) {
-	followerID, err := authentication.ExtractUserID(r)
+func (controllers *Controllers) UnFollowUser(w http.ResponseWriter, r *http.Request) {
+	followerID, err := authentication.UserIDFromRequest(r)
 	if err != nil {
 		templates.Error(w, http.StatusUnauthorized, err)
 		return
@@ -247,15 +280,7 @@ func UnFollowUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := database.Connect()
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer db.Close()
-
-	userRepository := repositories.NewUserRepository(db)
-	if err = userRepository.UnFollowUser(userID, followerID); err != nil {
+	if err = controllers.users.UnFollowUser(r.Context(), userID, followerID); err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -264,22 +289,14 @@ func UnFollowUser(w http.ResponseWriter, r *http.Request) {
 }
 
 // SearchFollowers get all followers of an user
-func SearchFollowers(w http.ResponseWriter, r *http.Request) {
+func (controllers *Controllers) SearchFollowers(w http.ResponseWriter, r *http.Request) {
 	param := mux.Vars(r)
 	userID, err := strconv.ParseUint(param["userId"], 10, 64)
 	if err != nil {
 		templates.Error(w, http.StatusBadRequest, err)
 	}
 
-	db, err := database.Connect()
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer db.Close()
-
-	userRepository := repositories.NewUserRepository(db)
-	followers, err := userRepository.SearchFollowers(userID)
+	followers, err := controllers.users.SearchFollowers(r.Context(), userID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
@@ -289,22 +306,14 @@ func SearchFollowers(w http.ResponseWriter, r *http.Request) {
 }
 
 // SearchFollowing get all users that an user follows
-func SearchFollowing(w http.ResponseWriter, r *http.Request) {
+func (controllers *Controllers) SearchFollowing(w http.ResponseWriter, r *http.Request) {
 	param := mux.Vars(r)
 	userID, err := strconv.ParseUint(param["userId"], 10, 64)
 	if err != nil {
 		templates.Error(w, http.StatusBadRequest, err)
 	}
 
-	db, err := database.Connect()
-	if err != nil {
-		templates.Error(w, http.StatusInternalServerError, err)
-		return
-	}
-	defer db.Close()
-
-	userRepository := repositories.NewUserRepository(db)
-	following, err := userRepository.SearchFollowing(userID)
+	following, err := controllers.users.SearchFollowing(r.Context(), userID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
@@ -313,8 +322,8 @@ func SearchFollowing(w http.ResponseWriter, r *http.Request) {
 	templates.JSON(w, http.StatusOK, following)
 }
 
-func UpdatePassword(w http.ResponseWriter, r *http.Request) {
-	tokenUserID, err := authentication.ExtractUserID(r)
+func (controllers *Controllers) UpdatePassword(w http.ResponseWriter, r *http.Request) {
+	tokenUserID, err := authentication.UserIDFromRequest(r)
 	if err != nil {
 		templates.Error(w, http.StatusBadRequest, err)
 		return
@@ -340,22 +349,25 @@ func UpdatePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db, err := database.Connect()
+	savedPasswordHash, err := controllers.users.SearchPassword(r.Context(), userID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	defer db.Close()
 
-	userRepository := repositories.NewUserRepository(db)
-	savedPasswordHash, err := userRepository.SearchPassword(userID)
+	if err = security.ValidatePassword(savedPasswordHash, password.Current); err != nil {
+		templates.Error(w, http.StatusUnauthorized, errors.New("the current password doesn't match users password"))
+		return
+	}
+
+	user, err := controllers.users.SerachByID(r.Context(), userID)
 	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	if err = security.ValidatePassword(savedPasswordHash, password.Current); err != nil {
-		templates.Error(w, http.StatusUnauthorized, errors.New("the current password doesn't match users password"))
+	if err = security.CheckPasswordStrength(password.New, user.Name, user.Nick, user.Email); err != nil {
+		templates.Error(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -365,7 +377,21 @@ func UpdatePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = userRepository.UpdatePassword(userID, string(hashedPassword)); err != nil {
+	// The old tokens stop working with the old password, and not without it
+	errUserNotFound := errors.New("user not found")
+	err = authentication.RevokeUserTokensWith(r.Context(), controllers.db, userID, func(tx *sql.Tx) error {
+		updated, err := controllers.users.WithTx(tx).UpdatePassword(r.Context(), userID, string(hashedPassword))
+		if err == nil && !updated {
+			err = errUserNotFound
+		}
+
+		return err
+	})
+	if errors.Is(err, errUserNotFound) {
+		templates.Error(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
 		templates.Error(w, http.StatusInternalServerError, err)
 		return
 	}
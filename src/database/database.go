@@ -2,22 +2,57 @@ package database
 
 import (
 	"api/src/config"
+	"api/src/models"
 	"database/sql"
-
-	_ "github.com/go-sql-driver/mysql" // Driver
+	"fmt"
 )
 
-// Connect open a connection to the database and return it, or an error if so
-func Connect() (db *sql.DB, err error) {
-	db, err = sql.Open("mysql", config.DBConnectionString)
+// DB is the pool of connections shared by the whole API, opened once by Open when the API starts
+var DB *sql.DB
+
+// Open opens the pool of connections to the database of DB_DRIVER, sized by the configuration, and checks that
+// the database answers. The pool is kept on DB and lasts while the API runs
+func Open() (db *sql.DB, err error) {
+	dialect, found := Dialects[config.DBDriver]
+	if !found {
+		err = fmt.Errorf("unknown DB_DRIVER %q, it can be mysql, postgres or sqlite", config.DBDriver)
+		return
+	}
+
+	db, err = sql.Open(dialect.driverName, config.DBConnectionString)
 	if err != nil {
 		return
 	}
 
+	db.SetMaxOpenConns(config.DBMaxOpenConnections)
+	db.SetMaxIdleConns(config.DBMaxIdleConnections)
+	db.SetConnMaxLifetime(config.DBConnectionMaxLifetime)
+	db.SetConnMaxIdleTime(config.DBConnectionMaxIdleTime)
+
 	if err = db.Ping(); err != nil {
 		db.Close()
 		return
 	}
 
+	DB, CurrentDialect = db, dialect
 	return
 }
+
+// Stats gives the statistics of the pool, to watch if it is too small (a growing wait count) or too large, and
+// the health of the replicas
+func Stats() models.DatabaseStats {
+	stats := DB.Stats()
+
+	return models.DatabaseStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration.String(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+		Replicas:           replicaStats(),
+	}
+}
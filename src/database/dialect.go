@@ -0,0 +1,222 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Dialect is what changes between the databases the API runs on. The repositories write their SQL for all of
+// them, with ? placeholders, and ask the dialect for the few statements that differ
+type Dialect struct {
+	// Name is the value of DB_DRIVER, and the folder of the dialect's migrations
+	Name string
+
+	// DateTime is the column type of dates with time
+	DateTime string
+
+	driverName   string
+	returningIDs bool
+	insertIgnore string
+	deadlock     func(err error) bool
+	replicaLag   func(ctx context.Context, db *sql.DB) (lag time.Duration, err error)
+}
+
+var (
+	// MySQL is the database the API was first written for
+	MySQL = Dialect{Name: "mysql", DateTime: "datetime", driverName: "mysql", insertIgnore: "insert ignore into %s",
+		deadlock: mysqlDeadlock, replicaLag: mysqlReplicaLag}
+
+	// PostgreSQL numbers its placeholders, which the driver registered here rewrites from ?, and only reports
+	// the ID of an inserted row when asked with returning. Dates keep their time zone, like the Go times
+	PostgreSQL = Dialect{Name: "postgres", DateTime: "timestamptz", driverName: "postgres-rebind", returningIDs: true,
+		insertIgnore: "insert into %s on conflict do nothing", deadlock: postgresDeadlock,
+		replicaLag: postgresReplicaLag}
+
+	// SQLite runs embedded on a file, so the API can run without a database server, and has no replicas
+	SQLite = Dialect{Name: "sqlite", DateTime: "datetime", driverName: "sqlite", insertIgnore: "insert into %s on conflict do nothing",
+		deadlock: sqliteBusy}
+
+	// CurrentDialect is the dialect of the database on DB, chosen by DB_DRIVER
+	CurrentDialect = MySQL
+)
+
+// Dialects are the supported dialects, by name
+var Dialects = map[string]Dialect{
+	MySQL.Name:      MySQL,
+	PostgreSQL.Name: PostgreSQL,
+	SQLite.Name:     SQLite,
+}
+
+func init() {
+	sql.Register(PostgreSQL.driverName, postgresDriver{})
+}
+
+// InsertIgnore builds an insert that does nothing when the row already exists, from its target and values:
+// InsertIgnore("followers (user_id, follower_id) values (?, ?)")
+func InsertIgnore(insert string) string {
+	return fmt.Sprintf(CurrentDialect.insertIgnore, insert)
+}
+
+// InsertReturningID runs an insert on a table with an auto incremented id column, giving the id of the new row
+func InsertReturningID(ctx context.Context, db DBTX, query string, args ...interface{}) (id uint64, err error) {
+	if CurrentDialect.returningIDs {
+		err = db.QueryRowContext(ctx, query+" returning id", args...).Scan(&id)
+		return
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+
+	lastInsertID, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	id = uint64(lastInsertID)
+
+	return
+}
+
+// mysqlDeadlock tells if InnoDB rolled the transaction back to break a deadlock
+func mysqlDeadlock(err error) bool {
+	var mysqlError *mysql.MySQLError
+	return errors.As(err, &mysqlError) && mysqlError.Number == 1213
+}
+
+// postgresDeadlock tells if PostgreSQL aborted the transaction on a deadlock or a serialization failure
+func postgresDeadlock(err error) bool {
+	var postgresError *pgconn.PgError
+	return errors.As(err, &postgresError) && (postgresError.Code == "40P01" || postgresError.Code == "40001")
+}
+
+// sqliteBusy tells if SQLite refused a lock the transaction would deadlock waiting for, which it reports as busy
+func sqliteBusy(err error) bool {
+	var sqliteError *sqlite.Error
+	return errors.As(err, &sqliteError) && sqliteError.Code()&0xff == sqlite3.SQLITE_BUSY
+}
+
+// mysqlReplicaLag reads how many seconds the replica is behind its source. A server that isn't replicating
+// has no lag, and a replica whose replication stopped has no number of seconds
+func mysqlReplicaLag(ctx context.Context, db *sql.DB) (lag time.Duration, err error) {
+	lines, err := db.QueryContext(ctx, "show replica status")
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	if !lines.Next() {
+		err = lines.Err()
+		return
+	}
+
+	columns, err := lines.Columns()
+	if err != nil {
+		return
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	if err = lines.Scan(pointers...); err != nil {
+		return
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+
+		if values[i] == nil {
+			return 0, errors.New("the replication is stopped")
+		}
+
+		seconds, err := strconv.Atoi(string(values[i]))
+		return time.Duration(seconds) * time.Second, err
+	}
+
+	return
+}
+
+// postgresReplicaLag reads how long ago the standby replayed the last transaction it received. A standby that
+// replayed everything it received has no lag, even when the primary had nothing to send for a while
+func postgresReplicaLag(ctx context.Context, db *sql.DB) (lag time.Duration, err error) {
+	var seconds float64
+	err = db.QueryRowContext(ctx, `
+		select case
+			when not pg_is_in_recovery() or pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() then 0
+			else coalesce(extract(epoch from now() - pg_last_xact_replay_timestamp()), 0)
+		end`,
+	).Scan(&seconds)
+
+	lag = time.Duration(seconds * float64(time.Second))
+	return
+}
+
+// postgresDriver is the pgx driver taking the ? placeholders of the other databases
+type postgresDriver struct{}
+
+func (postgresDriver) Open(name string) (driver.Conn, error) {
+	conn, err := stdlib.GetDefaultDriver().Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return postgresConn{conn.(*stdlib.Conn)}, nil
+}
+
+type postgresConn struct {
+	*stdlib.Conn
+}
+
+func (conn postgresConn) Prepare(query string) (driver.Stmt, error) {
+	return conn.Conn.Prepare(rebind(query))
+}
+
+func (conn postgresConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return conn.Conn.PrepareContext(ctx, rebind(query))
+}
+
+func (conn postgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return conn.Conn.ExecContext(ctx, rebind(query), args)
+}
+
+func (conn postgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return conn.Conn.QueryContext(ctx, rebind(query), args)
+}
+
+// rebind numbers the ? placeholders of a query ($1, $2...), leaving the ones inside quotes
+func rebind(query string) string {
+	var rebound strings.Builder
+	placeholder, quoted := 0, false
+
+	for _, char := range query {
+		switch {
+		case char == '\'':
+			quoted = !quoted
+		case char == '?' && !quoted:
+			placeholder++
+			fmt.Fprintf(&rebound, "$%d", placeholder)
+			continue
+		}
+
+		rebound.WriteRune(char)
+	}
+
+	return rebound.String()
+}
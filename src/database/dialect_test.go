@@ -0,0 +1,46 @@
+package database
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"without placeholders", "select id from users", "select id from users"},
+		{"one placeholder", "select id from users where id = ?", "select id from users where id = $1"},
+		{"many placeholders", "insert into users (name, nick) values (?,?)", "insert into users (name, nick) values ($1,$2)"},
+		{"a placeholder inside quotes", "select id from users where nick = '?' and id = ?", "select id from users where nick = '?' and id = $1"},
+		{"an escaped quote", "select id from users where nick = 'it''s ?' and id = ?", "select id from users where nick = 'it''s ?' and id = $1"},
+		{"after a quoted string", "update users set name = 'a', nick = ? where id = ?", "update users set name = 'a', nick = $1 where id = $2"},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := rebind(testCase.query); got != testCase.want {
+				t.Errorf("got %q, want %q", got, testCase.want)
+			}
+		})
+	}
+}
+
+func TestInsertIgnore(t *testing.T) {
+	defer func(dialect Dialect) { CurrentDialect = dialect }(CurrentDialect)
+
+	cases := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{MySQL, "insert ignore into followers (user_id, follower_id) values (?, ?)"},
+		{PostgreSQL, "insert into followers (user_id, follower_id) values (?, ?) on conflict do nothing"},
+		{SQLite, "insert into followers (user_id, follower_id) values (?, ?) on conflict do nothing"},
+	}
+
+	for _, testCase := range cases {
+		CurrentDialect = testCase.dialect
+		if got := InsertIgnore("followers (user_id, follower_id) values (?, ?)"); got != testCase.want {
+			t.Errorf("%s: got %q, want %q", testCase.dialect.Name, got, testCase.want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"api/src/config"
+	"api/src/models"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replica is a read replica of the database, with what its last health check found
+type replica struct {
+	name string
+	db   *sql.DB
+
+	mutex   sync.Mutex
+	healthy bool
+	lag     time.Duration
+	err     error
+}
+
+var (
+	replicas    []*replica
+	nextReplica atomic.Uint64
+)
+
+// OpenReplicas opens a pool of connections for each replica of DB_REPLICAS, sized like the one of the primary, and
+// checks their health every DB_REPLICA_CHECK_INTERVAL. A replica that is down or too far behind only sends its
+// reads back to the primary, so it doesn't stop the API from starting
+func OpenReplicas() (err error) {
+	if len(config.DBReplicas) == 0 {
+		return
+	}
+
+	if CurrentDialect.replicaLag == nil {
+		return fmt.Errorf("DB_REPLICAS isn't supported on %s", CurrentDialect.Name)
+	}
+
+	for i, connectionString := range config.DBReplicas {
+		db, err := sql.Open(CurrentDialect.driverName, connectionString)
+		if err != nil {
+			return fmt.Errorf("replica %d: %w", i+1, err)
+		}
+
+		db.SetMaxOpenConns(config.DBMaxOpenConnections)
+		db.SetMaxIdleConns(config.DBMaxIdleConnections)
+		db.SetConnMaxLifetime(config.DBConnectionMaxLifetime)
+		db.SetConnMaxIdleTime(config.DBConnectionMaxIdleTime)
+
+		replicas = append(replicas, &replica{name: fmt.Sprintf("replica %d", i+1), db: db})
+	}
+
+	checkReplicas()
+	go func() {
+		for range time.Tick(config.DBReplicaCheckInterval) {
+			checkReplicas()
+		}
+	}()
+
+	return
+}
+
+// checkReplicas marks as healthy the replicas that answer and aren't more than DB_REPLICA_MAX_LAG behind
+func checkReplicas() {
+	for _, replica := range replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), config.DBReplicaCheckInterval)
+		lag, err := CurrentDialect.replicaLag(ctx, replica.db)
+		cancel()
+
+		if err == nil && lag > config.DBReplicaMaxLag {
+			err = fmt.Errorf("it is %s behind the primary", lag)
+		}
+
+		replica.mutex.Lock()
+		if replica.healthy && err != nil {
+			log.Printf("%s stopped receiving reads: %v", replica.name, err)
+		}
+		if !replica.healthy && err == nil {
+			log.Printf("%s is receiving reads", replica.name)
+		}
+		replica.healthy, replica.lag, replica.err = err == nil, lag, err
+		replica.mutex.Unlock()
+	}
+}
+
+func (replica *replica) isHealthy() bool {
+	replica.mutex.Lock()
+	defer replica.mutex.Unlock()
+
+	return replica.healthy
+}
+
+// fail takes the replica out of the rotation until the next health check finds it working
+func (replica *replica) fail(err error) {
+	replica.mutex.Lock()
+	defer replica.mutex.Unlock()
+
+	if replica.healthy {
+		log.Printf("%s stopped receiving reads: %v", replica.name, err)
+	}
+	replica.healthy, replica.err = false, err
+}
+
+// Reader gives where a read only query of a repository on db runs. Repositories on the pool read from the
+// healthy replicas in turns, falling back to the primary when none is healthy or when the context asks for it.
+// Repositories on a transaction keep reading from it
+func Reader(ctx context.Context, db DBTX) DBTX {
+	if pool, onPool := db.(*sql.DB); !onPool || pool != DB || len(replicas) == 0 || readsFromPrimary(ctx) {
+		return db
+	}
+
+	for range replicas {
+		replica := replicas[nextReplica.Add(1)%uint64(len(replicas))]
+		if replica.isHealthy() {
+			return replicaReader{replica}
+		}
+	}
+
+	return db
+}
+
+// replicaReader runs queries on a replica, running them again on the primary when the replica can't be reached
+type replicaReader struct {
+	replica *replica
+}
+
+func (reader replicaReader) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return DB.ExecContext(ctx, query, args...)
+}
+
+func (reader replicaReader) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	statement, err := reader.replica.db.PrepareContext(ctx, query)
+	if unavailable(err) {
+		reader.replica.fail(err)
+		return DB.PrepareContext(ctx, query)
+	}
+
+	return statement, err
+}
+
+func (reader replicaReader) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	lines, err := reader.replica.db.QueryContext(ctx, query, args...)
+	if unavailable(err) {
+		reader.replica.fail(err)
+		return DB.QueryContext(ctx, query, args...)
+	}
+
+	return lines, err
+}
+
+func (reader replicaReader) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	line := reader.replica.db.QueryRowContext(ctx, query, args...)
+	if err := line.Err(); unavailable(err) {
+		reader.replica.fail(err)
+		return DB.QueryRowContext(ctx, query, args...)
+	}
+
+	return line
+}
+
+// unavailable tells if the error means the database couldn't be reached, and not that the query failed
+func unavailable(err error) bool {
+	var netErr *net.OpError
+	return errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr)
+}
+
+type primaryContextKey struct{}
+
+// WithPrimary makes the reads done with the context go to the primary, so they see the writes just made on it
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+func readsFromPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryContextKey{}).(bool)
+	return primary
+}
+
+// recentWrites keeps when each user last wrote, for DB_READ_YOUR_WRITES_WINDOW
+type recentWrites struct {
+	mutex      sync.Mutex
+	users      map[uint64]time.Time
+	lastPruned time.Time
+}
+
+var writes = recentWrites{users: map[uint64]time.Time{}}
+
+// RecordWrite records that the user has just written, so its reads go to the primary for a while
+func RecordWrite(userID uint64) {
+	if len(replicas) == 0 {
+		return
+	}
+
+	writes.mutex.Lock()
+	defer writes.mutex.Unlock()
+
+	now := time.Now()
+	writes.users[userID] = now
+
+	if now.Sub(writes.lastPruned) > config.DBReadYourWritesWindow {
+		for user, wroteAt := range writes.users {
+			if now.Sub(wroteAt) > config.DBReadYourWritesWindow {
+				delete(writes.users, user)
+			}
+		}
+		writes.lastPruned = now
+	}
+}
+
+// WroteRecently tells if the user wrote in the last DB_READ_YOUR_WRITES_WINDOW, when the replicas may not have
+// its changes yet
+func WroteRecently(userID uint64) bool {
+	writes.mutex.Lock()
+	defer writes.mutex.Unlock()
+
+	wroteAt, found := writes.users[userID]
+	return found && time.Since(wroteAt) <= config.DBReadYourWritesWindow
+}
+
+// replicaStats gives the health of each replica, for Stats
+func replicaStats() (stats []models.ReplicaStats) {
+	for _, replica := range replicas {
+		replica.mutex.Lock()
+		replicaStats := models.ReplicaStats{
+			Name:            replica.name,
+			Healthy:         replica.healthy,
+			Lag:             replica.lag.String(),
+			OpenConnections: replica.db.Stats().OpenConnections,
+		}
+		if replica.err != nil {
+			replicaStats.Error = replica.err.Error()
+		}
+		replica.mutex.Unlock()
+
+		stats = append(stats, replicaStats)
+	}
+
+	return
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"api/src/config"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DBTX is what the repositories run their queries on: the pool, or a transaction shared by the repositories of
+// a unit of work
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+var (
+	_ DBTX = (*sql.DB)(nil)
+	_ DBTX = (*sql.Tx)(nil)
+)
+
+// Transaction runs work as a unit of work: the repositories it creates on tx write together, committed when work
+// returns nil and rolled back when it returns an error or panics. When the database breaks a deadlock by rolling
+// the transaction back, work runs again on a new one, up to DB_DEADLOCK_RETRIES times, so it must only change
+// the database and the variables it sets on every run
+func Transaction(ctx context.Context, db *sql.DB, work func(tx *sql.Tx) error) (err error) {
+	for attempt := 0; ; attempt++ {
+		err = transaction(ctx, db, work)
+		if err == nil || attempt >= config.DBDeadlockRetries || !CurrentDialect.deadlock(err) {
+			return
+		}
+
+		// The transactions that deadlocked wait a growing time, so they don't collide again
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(attempt+1) * 10 * time.Millisecond):
+		}
+	}
+}
+
+func transaction(ctx context.Context, db *sql.DB, work func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			tx.Rollback()
+			panic(recovered)
+		}
+
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = work(tx); err != nil {
+		return
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,127 @@
+package lockout
+
+import (
+	"api/src/config"
+	"sync"
+	"time"
+)
+
+// Tracker counts the failed attempts of a key (like an email or an IP). After the allowed amount of
+// failures the key is locked out, each new failure doubling the lockout up to a maximum
+type Tracker struct {
+	mutex       sync.Mutex
+	attempts    map[string]*attempt
+	maxFailures int
+	baseLockout time.Duration
+	maxLockout  time.Duration
+	lastSweep   time.Time
+}
+
+type attempt struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+var (
+	// Accounts tracks the failed logins of each account
+	Accounts = NewTracker(5, 30*time.Second, time.Hour)
+
+	// IPs tracks the failed logins coming from each client IP
+	IPs = NewTracker(20, 30*time.Second, time.Hour)
+)
+
+// Load creates the trackers with the limits from the configuration
+func Load() {
+	Accounts = NewTracker(config.LoginMaxFailuresPerAccount, config.LoginBaseLockout, config.LoginMaxLockout)
+	IPs = NewTracker(config.LoginMaxFailuresPerIP, config.LoginBaseLockout, config.LoginMaxLockout)
+}
+
+// NewTracker creates a tracker that locks a key out after maxFailures failures
+func NewTracker(maxFailures int, baseLockout, maxLockout time.Duration) *Tracker {
+	return &Tracker{
+		attempts:    map[string]*attempt{},
+		maxFailures: maxFailures,
+		baseLockout: baseLockout,
+		maxLockout:  maxLockout,
+	}
+}
+
+// RetryAfter returns how long the key is still locked out, or zero if it can try again
+func (tracker *Tracker) RetryAfter(key string) time.Duration {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	attempt, found := tracker.attempts[key]
+	if !found {
+		return 0
+	}
+
+	if retryAfter := time.Until(attempt.lockedUntil); retryAfter > 0 {
+		return retryAfter
+	}
+
+	return 0
+}
+
+// Fail records a failed attempt of the key, returning until when this failure locked it out (zero when it didn't)
+func (tracker *Tracker) Fail(key string) (failures int, lockedUntil time.Time) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	now := time.Now()
+	tracker.sweep(now)
+
+	currentAttempt, found := tracker.attempts[key]
+	if !found {
+		currentAttempt = &attempt{}
+		tracker.attempts[key] = currentAttempt
+	}
+
+	currentAttempt.failures++
+	currentAttempt.lastFailure = now
+
+	failures = currentAttempt.failures
+	if exceeding := failures - tracker.maxFailures; exceeding >= 0 {
+		lockedUntil = now.Add(tracker.lockoutDuration(exceeding))
+		currentAttempt.lockedUntil = lockedUntil
+	}
+
+	return
+}
+
+// Succeed forgets the failures of the key
+func (tracker *Tracker) Succeed(key string) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	delete(tracker.attempts, key)
+}
+
+// lockoutDuration doubles the base lockout for each failure above the maximum
+func (tracker *Tracker) lockoutDuration(exceeding int) time.Duration {
+	lockout := tracker.baseLockout
+	for i := 0; i < exceeding && lockout < tracker.maxLockout; i++ {
+		lockout *= 2
+	}
+
+	if lockout > tracker.maxLockout {
+		return tracker.maxLockout
+	}
+
+	return lockout
+}
+
+// sweep forgets the keys that are not locked and didn't fail for the maximum lockout. Must hold the mutex
+func (tracker *Tracker) sweep(now time.Time) {
+	if now.Sub(tracker.lastSweep) < tracker.maxLockout {
+		return
+	}
+	tracker.lastSweep = now
+
+	for key, attempt := range tracker.attempts {
+		if now.After(attempt.lockedUntil) && now.Sub(attempt.lastFailure) > tracker.maxLockout {
+			delete(tracker.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"api/src/config"
+	"fmt"
+	"os"
+)
+
+// Message is an email sent by the API
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(message Message) error
+}
+
+// Sender is the mailer used by the API, chosen by the configuration on Load
+var Sender Mailer = NewWriterMailer(os.Stdout)
+
+// Load chooses the mailer based on MAIL_DRIVER (smtp, file or stdout)
+func Load() (err error) {
+	switch config.MailDriver {
+	case "smtp":
+		Sender = NewSMTPMailer(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword, config.MailFrom)
+	case "file":
+		Sender, err = NewFileMailer(config.MailFile)
+	case "", "stdout":
+		Sender = NewWriterMailer(os.Stdout)
+	default:
+		err = fmt.Errorf("unknown mail driver %q", config.MailDriver)
+	}
+
+	return
+}
+
+// Send sends a message with the configured mailer
+func Send(message Message) error {
+	return Sender.Send(message)
+}
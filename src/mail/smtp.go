@@ -0,0 +1,46 @@
+package mail
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPMailer sends emails through a SMTP server
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer creates a mailer that sends through the given SMTP server, authenticating when there is an username
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{host, port, username, password, from}
+}
+
+// Send delivers the message to the SMTP server
+func (smtpMailer *SMTPMailer) Send(message Message) error {
+	var auth smtp.Auth
+	if smtpMailer.username != "" {
+		auth = smtp.PlainAuth("", smtpMailer.username, smtpMailer.password, smtpMailer.host)
+	}
+
+	address := fmt.Sprintf("%s:%d", smtpMailer.host, smtpMailer.port)
+	return smtp.SendMail(address, auth, smtpMailer.from, []string{message.To}, smtpMailer.format(message))
+}
+
+func (smtpMailer *SMTPMailer) format(message Message) []byte {
+	var content strings.Builder
+
+	fmt.Fprintf(&content, "From: %s\r\n", smtpMailer.from)
+	fmt.Fprintf(&content, "To: %s\r\n", message.To)
+	fmt.Fprintf(&content, "Subject: %s\r\n", message.Subject)
+	content.WriteString("MIME-Version: 1.0\r\n")
+	content.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	content.WriteString("\r\n")
+	content.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return []byte(content.String())
+}
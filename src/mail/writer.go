@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// WriterMailer writes the emails to a writer instead of sending them, useful on development and tests
+type WriterMailer struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// NewWriterMailer creates a mailer that writes every message to the writer
+func NewWriterMailer(writer io.Writer) *WriterMailer {
+	return &WriterMailer{writer: writer}
+}
+
+// NewFileMailer creates a mailer that appends every message to a file
+func NewFileMailer(path string) (*WriterMailer, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWriterMailer(file), nil
+}
+
+// Send writes the message
+func (writerMailer *WriterMailer) Send(message Message) (err error) {
+	writerMailer.mutex.Lock()
+	defer writerMailer.mutex.Unlock()
+
+	_, err = fmt.Fprintf(writerMailer.writer,
+		"Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC1123Z),
+		message.To,
+		message.Subject,
+		message.Body,
+	)
+	return
+}
@@ -2,9 +2,16 @@ package middlewares
 
 import (
 	"api/src/authentication"
+	"api/src/config"
+	"api/src/database"
 	"api/src/templates"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 )
 
 // Logger writes requests informations inside the router
@@ -15,13 +22,139 @@ func Logger(nextFunction http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// Authenticates if a user is authenticated
-func Authenticates(nextFunction http.HandlerFunc) http.HandlerFunc {
+// Deadline limits how long the database can work on a request, so a slow query or a full pool is answered
+// with an error instead of holding the client
+func Deadline(nextFunction http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := authentication.ValidateToken(r); err != nil {
+		if config.DBRequestTimeout <= 0 {
+			nextFunction(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), config.DBRequestTimeout)
+		defer cancel()
+
+		nextFunction(w, r.WithContext(ctx))
+	}
+}
+
+// ReadYourWrites sends the reads of a request to the primary database when its route writes, or when its user
+// wrote in the last DB_READ_YOUR_WRITES_WINDOW, so nobody misses their own changes while the replicas catch up
+func ReadYourWrites(writes bool, nextFunction http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var userID uint64
+		if claims, err := authentication.ClaimsFromRequest(r); err == nil {
+			userID = claims.UserID
+		}
+
+		if writes {
+			if userID != 0 {
+				// Recorded again at the end, since the window starts when the writes are done
+				database.RecordWrite(userID)
+				defer database.RecordWrite(userID)
+			}
+
+			nextFunction(w, r.WithContext(database.WithPrimary(r.Context())))
+			return
+		}
+
+		if userID != 0 && database.WroteRecently(userID) {
+			r = r.WithContext(database.WithPrimary(r.Context()))
+		}
+		nextFunction(w, r)
+	}
+}
+
+// Authenticates if a user is authenticated, checking on db that its token wasn't revoked. Writes tells if the route changes something, which read only
+// impersonations can't do
+func Authenticates(db *sql.DB, writes bool, nextFunction http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := authentication.ValidateToken(db, r)
+		if err != nil {
 			templates.Error(w, http.StatusUnauthorized, err)
 			return
 		}
+
+		if claims.Actor != nil {
+			impersonate(w, authentication.WithClaims(r, claims), db, claims, writes, nextFunction)
+			return
+		}
+		nextFunction(w, authentication.WithClaims(r, claims))
+	}
+}
+
+// impersonate serves a request made by an admin acting as another user, blocking writes when the impersonation
+// is read only and attributing the request to both of them on the log and on the audit trail
+func impersonate(w http.ResponseWriter, r *http.Request, db *sql.DB, claims *authentication.Claims, writes bool, nextFunction http.HandlerFunc) {
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	switch {
+	case claims.ReadOnly && writes && !endsImpersonation(r):
+		templates.Error(recorder, http.StatusForbidden, errors.New("this impersonation is read only"))
+	default:
+		nextFunction(recorder, r)
+	}
+
+	log.Printf("user %d impersonating user %d: %s %s %d", claims.Actor.UserID, claims.UserID, r.Method, r.URL.Path, recorder.status)
+	// Recorded even when the request ran out of time
+	if err := authentication.RecordImpersonatedRequest(context.WithoutCancel(r.Context()), db, claims, r.Method, r.URL.Path, recorder.status); err != nil {
+		log.Printf("recording the impersonated request: %v", err)
+	}
+}
+
+// endsImpersonation tells if the request is the logout, which ends the impersonation and so is allowed even when
+// it is read only
+func endsImpersonation(r *http.Request) bool {
+	return r.Method == http.MethodPost && r.URL.Path == "/logout"
+}
+
+// statusRecorder keeps the status code written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+// RequireScopes blocks principals limited by scopes (like API keys) that weren't granted the route's scopes.
+// Routes without scopes are only reachable by tokens from an interactive login
+func RequireScopes(scopes []string, nextFunction http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := authentication.ClaimsFromRequest(r)
+		if err != nil {
+			templates.Error(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		if claims.Restricted() && len(scopes) == 0 {
+			templates.Error(w, http.StatusForbidden, errors.New("this route is not available for scoped tokens"))
+			return
+		}
+
+		if !claims.HasScopes(scopes...) {
+			templates.Error(w, http.StatusForbidden, fmt.Errorf("this route requires the scopes %s", strings.Join(scopes, ", ")))
+			return
+		}
+		nextFunction(w, r)
+	}
+}
+
+// RequirePermissions blocks principals whose roles don't grant every one of the route's permissions
+func RequirePermissions(permissions []string, nextFunction http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := authentication.ClaimsFromRequest(r)
+		if err != nil {
+			templates.Error(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		if !claims.Can(permissions...) {
+			templates.Error(w, http.StatusForbidden, fmt.Errorf("this route requires the permissions %s", strings.Join(permissions, ", ")))
+			return
+		}
 		nextFunction(w, r)
 	}
 }
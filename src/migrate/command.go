@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// Usage describes the migrate subcommand of the API
+const Usage = `usage: api migrate up|down|status|to VERSION
+
+  up          applies every pending migration
+  down        undoes the last applied migration
+  status      lists the migrations and when they were applied
+  to VERSION  applies or undoes migrations until the schema is at VERSION (0 undoes all of them)`
+
+// Run runs the migrate subcommand with its arguments, writing the status to out
+func Run(ctx context.Context, db *sql.DB, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		return Up(ctx, db)
+
+	case args[0] == "down" && len(args) == 1:
+		return Down(ctx, db)
+
+	case args[0] == "to" && len(args) == 2:
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+
+		return To(ctx, db, version)
+
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := Status(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tSTATUS")
+		for _, status := range statuses {
+			state := "pending"
+			switch {
+			case status.Dirty:
+				state = "dirty"
+			case status.AppliedAt != nil:
+				state = "applied at " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+
+			fmt.Fprintf(writer, "%d\t%s\t%s\n", status.Version, status.Name, state)
+		}
+
+		return writer.Flush()
+	}
+
+	return errors.New(Usage)
+}
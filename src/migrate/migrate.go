@@ -0,0 +1,355 @@
+// Package migrate applies the migrations embedded on the migrations package, recording on the schema_migrations
+// table which versions a database is at
+package migrate
+
+import (
+	"api/migrations"
+	"api/src/database"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is a numbered change of the schema, with the statements that apply and undo it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus tells if a migration was applied on the database. A dirty migration failed halfway
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+	Dirty     bool
+}
+
+type appliedMigration struct {
+	appliedAt time.Time
+	dirty     bool
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the embedded migrations of the current database dialect, ordered by version. Every version needs
+// an up and a down file
+func Load() (loaded []Migration, err error) {
+	files, err := fs.Sub(migrations.Files, database.CurrentDialect.Name)
+	if err != nil {
+		return
+	}
+
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("the migration %s isn't named [VERSION]_[NAME].up.sql or .down.sql", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(files, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, found := byVersion[version]
+		if !found {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("the migration %d has two names, %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("the migration %d needs both an up and a down file", migration.Version)
+		}
+		loaded = append(loaded, *migration)
+	}
+
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].Version < loaded[j].Version })
+	return
+}
+
+// Status lists every migration, telling which ones the database has applied
+func Status(ctx context.Context, db *sql.DB) (statuses []MigrationStatus, err error) {
+	loaded, err := Load()
+	if err != nil {
+		return
+	}
+
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return
+	}
+
+	for _, migration := range loaded {
+		status := MigrationStatus{Migration: migration}
+		if appliedMigration, found := applied[migration.Version]; found {
+			appliedAt := appliedMigration.appliedAt
+			status.AppliedAt = &appliedAt
+			status.Dirty = appliedMigration.dirty
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return
+}
+
+// Up applies every pending migration
+func Up(ctx context.Context, db *sql.DB) error {
+	loaded, err := Load()
+	if err != nil {
+		return err
+	}
+
+	if len(loaded) == 0 {
+		return fmt.Errorf("there are no migrations for %s", database.CurrentDialect.Name)
+	}
+
+	return To(ctx, db, loaded[len(loaded)-1].Version)
+}
+
+// Down undoes the last applied migration
+func Down(ctx context.Context, db *sql.DB) error {
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	last := 0
+	for version := range applied {
+		last = max(last, version)
+	}
+
+	if last == 0 {
+		return fmt.Errorf("there are no migrations to undo")
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		return err
+	}
+
+	previous := 0
+	for _, migration := range loaded {
+		if migration.Version < last {
+			previous = migration.Version
+		}
+	}
+
+	return To(ctx, db, previous)
+}
+
+// To moves the schema to a version, applying the pending migrations up to it and undoing the ones after it.
+// Version 0 undoes every migration
+func To(ctx context.Context, db *sql.DB, version int) error {
+	loaded, err := Load()
+	if err != nil {
+		return err
+	}
+
+	if version != 0 && !exists(loaded, version) {
+		return fmt.Errorf("there is no migration %d", version)
+	}
+
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for appliedVersion, appliedMigration := range applied {
+		if appliedMigration.dirty {
+			return dirtyError(appliedVersion)
+		}
+	}
+
+	for i := len(loaded) - 1; i >= 0; i-- {
+		if _, found := applied[loaded[i].Version]; found && loaded[i].Version > version {
+			if err = down(ctx, db, loaded[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, migration := range loaded {
+		if _, found := applied[migration.Version]; !found && migration.Version <= version {
+			if err = up(ctx, db, migration); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Check fails when the database is missing migrations, or one of them failed halfway, so the API doesn't
+// serve requests with a schema older than its code
+func Check(ctx context.Context, db *sql.DB) error {
+	statuses, err := Status(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if status.Dirty {
+			return dirtyError(status.Version)
+		}
+
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+
+	if pending > 0 {
+		return fmt.Errorf("the database schema is behind by %d migrations, run the API with `migrate up` first", pending)
+	}
+
+	return nil
+}
+
+func up(ctx context.Context, db *sql.DB, migration Migration) (err error) {
+	// Marked dirty first: MySQL commits every schema change on its own, so a failure can't be rolled back on
+	// every database
+	if _, err = db.ExecContext(ctx,
+		"insert into schema_migrations (version, name, dirty, applied_at) values (?, ?, ?, ?)",
+		migration.Version, migration.Name, true, time.Now(),
+	); err != nil {
+		return
+	}
+
+	if err = execute(ctx, db, migration.Up); err != nil {
+		return fmt.Errorf("applying the migration %d %s: %w", migration.Version, migration.Name, err)
+	}
+
+	if _, err = db.ExecContext(ctx, "update schema_migrations set dirty = ? where version = ?", false, migration.Version); err != nil {
+		return
+	}
+
+	log.Printf("applied the migration %d %s", migration.Version, migration.Name)
+	return
+}
+
+func down(ctx context.Context, db *sql.DB, migration Migration) (err error) {
+	if _, err = db.ExecContext(ctx, "update schema_migrations set dirty = ? where version = ?", true, migration.Version); err != nil {
+		return
+	}
+
+	if err = execute(ctx, db, migration.Down); err != nil {
+		return fmt.Errorf("undoing the migration %d %s: %w", migration.Version, migration.Name, err)
+	}
+
+	if _, err = db.ExecContext(ctx, "delete from schema_migrations where version = ?", migration.Version); err != nil {
+		return
+	}
+
+	log.Printf("undid the migration %d %s", migration.Version, migration.Name)
+	return
+}
+
+// execute runs the statements of a migration one by one, since the driver doesn't run many on a single call
+func execute(ctx context.Context, db *sql.DB, statements string) error {
+	for _, statement := range splitStatements(statements) {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitStatements splits a migration on the semicolons that end a line, skipping comments
+func splitStatements(migration string) (statements []string) {
+	var statement strings.Builder
+	for _, line := range strings.Split(migration, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		statement.WriteString(line)
+		statement.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(statement.String()))
+			statement.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(statement.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return
+}
+
+func appliedMigrations(ctx context.Context, db *sql.DB) (applied map[int]appliedMigration, err error) {
+	if _, err = db.ExecContext(ctx, fmt.Sprintf(`
+		create table if not exists schema_migrations(
+			version int primary key,
+			name varchar(255) not null,
+			dirty boolean not null,
+			applied_at %s not null
+		)`, database.CurrentDialect.DateTime),
+	); err != nil {
+		return
+	}
+
+	lines, err := db.QueryContext(ctx, "select version, dirty, applied_at from schema_migrations")
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	applied = map[int]appliedMigration{}
+	for lines.Next() {
+		var version int
+		var migration appliedMigration
+		if err = lines.Scan(&version, &migration.dirty, &migration.appliedAt); err != nil {
+			return
+		}
+
+		applied[version] = migration
+	}
+
+	err = lines.Err()
+	return
+}
+
+func exists(loaded []Migration, version int) bool {
+	for _, migration := range loaded {
+		if migration.Version == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+func dirtyError(version int) error {
+	return fmt.Errorf("the migration %d failed halfway: fix the schema by hand, then delete its row from schema_migrations", version)
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// APIKey represents a personal key that bots and integrations use to act as the user, limited to its scopes
+type APIKey struct {
+	ID         uint64     `json:"id"`
+	UserID     uint64     `json:"userId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+
+	// Key is only filled when the key is created, since it isn't stored
+	Key string `json:"key,omitempty"`
+}
+
+// Prepare validates and formats the API key for database insertion
+func (apiKey *APIKey) Prepare() error {
+	apiKey.Name = strings.TrimSpace(apiKey.Name)
+
+	if apiKey.Name == "" {
+		return errors.New(FieldisEmptyMessage("name"))
+	}
+
+	if len(apiKey.Scopes) == 0 {
+		return errors.New(FieldisEmptyMessage("scopes"))
+	}
+
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now()) {
+		return errors.New("the expiration date must be on the future")
+	}
+
+	return nil
+}
+
+// Expired reports if the key can't be used anymore
+func (apiKey APIKey) Expired() bool {
+	return apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt)
+}
@@ -0,0 +1,14 @@
+package models
+
+// AuthenticationData is what the API returns to a client after a successful authentication
+type AuthenticationData struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+// RefreshRequest presents the request format to rotate a refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
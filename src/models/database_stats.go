@@ -0,0 +1,25 @@
+package models
+
+// DatabaseStats are the statistics of the pool of connections to the database
+type DatabaseStats struct {
+	MaxOpenConnections int    `json:"maxOpenConnections"`
+	OpenConnections    int    `json:"openConnections"`
+	InUse              int    `json:"inUse"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"waitCount"`
+	WaitDuration       string `json:"waitDuration"`
+	MaxIdleClosed      int64  `json:"maxIdleClosed"`
+	MaxIdleTimeClosed  int64  `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed  int64  `json:"maxLifetimeClosed"`
+
+	Replicas []ReplicaStats `json:"replicas,omitempty"`
+}
+
+// ReplicaStats tell if a read replica is receiving reads, from its last health check
+type ReplicaStats struct {
+	Name            string `json:"name"`
+	Healthy         bool   `json:"healthy"`
+	Lag             string `json:"lag"`
+	Error           string `json:"error,omitempty"`
+	OpenConnections int    `json:"openConnections"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// EmailVerification represents a single use token, sent to an email address, that proves the user owns it
+type EmailVerification struct {
+	ID        uint64
+	UserID    uint64
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// Expired reports if the token can't be used anymore because of its age
+func (emailVerification EmailVerification) Expired() bool {
+	return time.Now().After(emailVerification.ExpiresAt)
+}
+
+// EmailVerificationRequest presents the request format to verify an email address
+type EmailVerificationRequest struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Impersonation records an admin acting as another user, from the token it got until it ends
+type Impersonation struct {
+	ID        uint64     `json:"id"`
+	ActorID   uint64     `json:"actorId"`
+	TargetID  uint64     `json:"targetId"`
+	Reason    string     `json:"reason"`
+	ReadOnly  bool       `json:"readOnly"`
+	TokenID   string     `json:"-"`
+	StartedAt time.Time  `json:"startedAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	EndedAt   *time.Time `json:"endedAt"`
+}
+
+// ImpersonationStart presents the request format to impersonate an user. Writes are blocked unless allowed
+type ImpersonationStart struct {
+	Reason      string `json:"reason"`
+	AllowWrites bool   `json:"allowWrites"`
+}
+
+// Prepare validates and formats the request
+func (impersonationStart *ImpersonationStart) Prepare() error {
+	impersonationStart.Reason = strings.TrimSpace(impersonationStart.Reason)
+
+	if impersonationStart.Reason == "" {
+		return errors.New(FieldisEmptyMessage("reason"))
+	}
+
+	if len(impersonationStart.Reason) > 255 {
+		return errors.New("the reason must have at most 255 characters")
+	}
+
+	return nil
+}
+
+// ImpersonationToken presents the response format of an impersonation, without a refresh token
+type ImpersonationToken struct {
+	ImpersonationID uint64 `json:"impersonationId"`
+	AccessToken     string `json:"accessToken"`
+	TokenType       string `json:"tokenType"`
+	ExpiresIn       int64  `json:"expiresIn"`
+	ReadOnly        bool   `json:"readOnly"`
+}
+
+// ImpersonatedRequest records a request made with an impersonation token
+type ImpersonatedRequest struct {
+	ID              uint64    `json:"id"`
+	ImpersonationID uint64    `json:"impersonationId"`
+	Method          string    `json:"method"`
+	Path            string    `json:"path"`
+	Status          int       `json:"status"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LockoutEvent records when an account or an IP was locked out for failing to login too many times
+type LockoutEvent struct {
+	ID          uint64    `json:"id"`
+	Kind        string    `json:"kind"`
+	Identifier  string    `json:"identifier"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"lockedUntil"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
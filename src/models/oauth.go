@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OAuthClient represents a third-party application registered to act on behalf of users
+type OAuthClient struct {
+	ID           uint64    `json:"id"`
+	ClientID     string    `json:"clientId"`
+	UserID       uint64    `json:"userId"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirectUris"`
+	Confidential bool      `json:"confidential"`
+	SecretHash   string    `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+
+	// ClientSecret is only filled when a confidential client is registered, since it isn't stored
+	ClientSecret string `json:"clientSecret,omitempty"`
+}
+
+// Prepare validates and formats the client for database insertion
+func (client *OAuthClient) Prepare() error {
+	client.Name = strings.TrimSpace(client.Name)
+
+	if client.Name == "" {
+		return errors.New(FieldisEmptyMessage("name"))
+	}
+
+	if len(client.RedirectURIs) == 0 {
+		return errors.New(FieldisEmptyMessage("redirectUris"))
+	}
+
+	for _, redirectURI := range client.RedirectURIs {
+		parsedURI, err := url.Parse(redirectURI)
+		if err != nil || !parsedURI.IsAbs() || parsedURI.Fragment != "" || strings.ContainsAny(redirectURI, " ") {
+			return errors.New("the redirect URIs must be absolute URIs without fragments")
+		}
+	}
+
+	return nil
+}
+
+// AllowsRedirectURI reports if the redirect URI was registered by the client, compared exactly
+func (client OAuthClient) AllowsRedirectURI(redirectURI string) bool {
+	for _, registeredURI := range client.RedirectURIs {
+		if registeredURI == redirectURI {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OAuthAuthorizationRequest presents the parameters of an authorization request, on the query or on the body
+type OAuthAuthorizationRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Approve             bool   `json:"approve"`
+}
+
+// OAuthConsent presents what a client is asking for, so the user can approve it
+type OAuthConsent struct {
+	Client        OAuthClient `json:"client"`
+	Scopes        []string    `json:"scopes"`
+	GrantedScopes []string    `json:"grantedScopes"`
+}
+
+// OAuthRedirect presents where the user agent must be sent back with the result of the authorization
+type OAuthRedirect struct {
+	RedirectURI string `json:"redirectUri"`
+}
+
+// OAuthAuthorizationCode represents a single use code, exchanged by the client for tokens
+type OAuthAuthorizationCode struct {
+	ID            uint64
+	CodeHash      string
+	ClientID      string
+	UserID        uint64
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+	SessionID     *string
+	ExpiresAt     time.Time
+	UsedAt        *time.Time
+}
+
+// Expired reports if the code can't be used anymore because of its age
+func (code OAuthAuthorizationCode) Expired() bool {
+	return time.Now().After(code.ExpiresAt)
+}
+
+// OAuthGrant represents the scopes an user granted to a client
+type OAuthGrant struct {
+	UserID    uint64    `json:"userId"`
+	ClientID  string    `json:"clientId"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OAuthToken presents the response format of the token endpoint, as defined by the OAuth 2.0 specification
+type OAuthToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthError presents the error format of the token and revocation endpoints, as defined by the OAuth 2.0 specification
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
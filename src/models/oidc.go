@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// OIDCState represents a login started on an OpenID Connect provider, waiting for the user to come back
+type OIDCState struct {
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// Expired reports if the login can't be finished anymore because of its age
+func (state OIDCState) Expired() bool {
+	return time.Now().After(state.ExpiresAt)
+}
+
+// UserIdentity links an user to its account on an OpenID Connect provider
+type UserIdentity struct {
+	UserID   uint64
+	Provider string
+	Subject  string
+}
+
+// OIDCProviders presents the OpenID Connect providers users can sign in with
+type OIDCProviders struct {
+	Providers []string `json:"providers"`
+}
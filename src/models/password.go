@@ -5,3 +5,14 @@ type Password struct {
 	New     string `json: new`
 	Current string `json: current`
 }
+
+// PasswordForgot presents the request format to ask for a password reset link
+type PasswordForgot struct {
+	Email string `json:"email"`
+}
+
+// PasswordReset presents the request format to choose a new password with a reset token
+type PasswordReset struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PasswordResetToken represents a single use token, sent by email, that lets an user choose a new password
+type PasswordResetToken struct {
+	ID        uint64
+	UserID    uint64
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// Expired reports if the token can't be used anymore because of its age
+func (passwordResetToken PasswordResetToken) Expired() bool {
+	return time.Now().After(passwordResetToken.ExpiresAt)
+}
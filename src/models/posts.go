@@ -15,6 +15,12 @@ type Post struct {
 	AuthorNick string    `json: "authorNick, omitempty"`
 	Likes      uint64    `json: "likes"`
 	CreatedAt  time.Time `json: "createdAt, omitempty"`
+
+	// Version counts the edits of the post, and is its ETag
+	Version uint64 `json:"version"`
+
+	// DeletedAt is when the post was deleted, only known while it can be restored
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
 }
 
 // Prepare post for database insertion
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// RefreshToken represents a long lived, single use token that can be exchanged for a new access token.
+// Every rotation creates a new token on the same family, so a reused token can revoke all of them at once.
+type RefreshToken struct {
+	ID        uint64
+	UserID    uint64
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// Expired reports if the token can't be used anymore because of its age
+func (refreshToken RefreshToken) Expired() bool {
+	return time.Now().After(refreshToken.ExpiresAt)
+}
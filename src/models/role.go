@@ -0,0 +1,28 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// Role represents a set of permissions that can be granted to users
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// RoleGrant presents the request format to grant a role to an user
+type RoleGrant struct {
+	Role string `json:"role"`
+}
+
+// Prepare validates and formats the grant
+func (roleGrant *RoleGrant) Prepare() error {
+	roleGrant.Role = strings.ToLower(strings.TrimSpace(roleGrant.Role))
+
+	if roleGrant.Role == "" {
+		return errors.New(FieldisEmptyMessage("role"))
+	}
+
+	return nil
+}
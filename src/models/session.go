@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Session represents a login of the user on a device, or an authorization given to an OAuth client,
+// which lasts while its refresh tokens are renewed
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     uint64     `json:"userId"`
+	ClientID   string     `json:"clientId,omitempty"`
+	Scopes     []string   `json:"scopes,omitempty"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	RevokedAt  *time.Time `json:"-"`
+
+	// Current tells the session of the token used on the request
+	Current bool `json:"current"`
+}
@@ -0,0 +1,37 @@
+package models
+
+// TOTP represents the time based one-time password (two-factor authentication) settings of an user
+type TOTP struct {
+	Secret   string
+	Enabled  bool
+	LastStep uint64
+}
+
+// TOTPEnrollment presents the response format of a TOTP enrollment
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// SecondFactor presents the request format to prove the second factor, with a TOTP code or a recovery code
+type SecondFactor struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// RecoveryCodes presents the response format with new recovery codes, shown only once
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// MFAChallenge is returned by the login when the user still needs to prove the second factor
+type MFAChallenge struct {
+	MFAToken  string `json:"mfaToken"`
+	ExpiresIn int64  `json:"expiresIn"`
+}
+
+// MFALogin presents the request format to finish a login that requires the second factor
+type MFALogin struct {
+	MFAToken string `json:"mfaToken"`
+	SecondFactor
+}
@@ -15,8 +15,13 @@ type User struct {
 	Name      string    `json: "name, omitempty"`
 	Nick      string    `json: "nick, omitempty"`
 	Email     string    `json: "email, omitempty"`
-	Password  string    `gorm:"size:100" json: "password, omitempty"`
+	Password  string    `gorm:"size:255" json: "password, omitempty"`
 	CreatedAt time.Time `json: "CreatedAt, omitempty" `
+
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+
+	// Version counts the edits of the profile, and is its ETag
+	Version uint64 `json:"version"`
 }
 
 // Prepare will call validate and format methods on the user
@@ -51,6 +56,12 @@ func (user *User) validate(step string) error {
 		return errors.New(FieldisEmptyMessage("password"))
 	}
 
+	if step == CREATE {
+		if err := security.CheckPasswordStrength(user.Password, user.Name, user.Nick, user.Email); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
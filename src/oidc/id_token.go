@@ -0,0 +1,274 @@
+package oidc
+
+import (
+	"api/src/models"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// clockSkew is how far the clocks of the API and of the provider can disagree
+const clockSkew = time.Minute
+
+// Identity is the user as told by the provider on a verified ID token
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+type idTokenClaims struct {
+	Issuer            string       `json:"iss"`
+	Subject           string       `json:"sub"`
+	Audience          audience     `json:"aud"`
+	AuthorizedParty   string       `json:"azp"`
+	ExpiresAt         int64        `json:"exp"`
+	IssuedAt          int64        `json:"iat"`
+	Nonce             string       `json:"nonce"`
+	Email             string       `json:"email"`
+	EmailVerified     flexibleBool `json:"email_verified"`
+	Name              string       `json:"name"`
+	PreferredUsername string       `json:"preferred_username"`
+}
+
+// Valid checks the times of the token, the other claims are checked against the provider
+func (claims idTokenClaims) Valid() error {
+	now := time.Now()
+
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return errors.New("the ID token is expired")
+	}
+
+	if now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)) {
+		return errors.New("the ID token was issued in the future")
+	}
+
+	return nil
+}
+
+// audience accepts the aud claim as a single string or as a list
+type audience []string
+
+func (aud *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*aud = audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	*aud = list
+	return nil
+}
+
+func (aud audience) contains(clientID string) bool {
+	for _, value := range aud {
+		if value == clientID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// flexibleBool accepts booleans sent as strings, like some providers do with email_verified
+type flexibleBool bool
+
+func (value *flexibleBool) UnmarshalJSON(data []byte) error {
+	var boolean bool
+	if err := json.Unmarshal(data, &boolean); err == nil {
+		*value = flexibleBool(boolean)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+
+	*value = text == "true"
+	return nil
+}
+
+// verifyIDToken checks the signature, the issuer, the audience and the nonce of an ID token
+func (provider *Provider) verifyIDToken(rawIDToken, nonce string) (identity Identity, err error) {
+	metadata, err := provider.discover()
+	if err != nil {
+		return
+	}
+
+	var claims idTokenClaims
+	if _, err = jwt.ParseWithClaims(rawIDToken, &claims, provider.verificationKey); err != nil {
+		return
+	}
+
+	if claims.Issuer != metadata.Issuer {
+		err = errors.New("the ID token was issued by another provider")
+		return
+	}
+
+	if !claims.Audience.contains(provider.ClientID) {
+		err = errors.New("the ID token was issued to another client")
+		return
+	}
+
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != provider.ClientID {
+		err = errors.New("the ID token was authorized to another client")
+		return
+	}
+
+	if nonce == "" || claims.Nonce != nonce {
+		err = errors.New("the ID token nonce doesn't match the login")
+		return
+	}
+
+	if claims.Subject == "" {
+		err = errors.New("the ID token has no subject")
+		return
+	}
+
+	identity = Identity{
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     bool(claims.EmailVerified),
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}
+	return
+}
+
+// verificationKey finds the key of the provider that signed the token, fetching the keys again when the
+// provider rotated them (at most once a minute)
+func (provider *Provider) verificationKey(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+	default:
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+
+	keyID, _ := token.Header["kid"].(string)
+
+	provider.mutex.Lock()
+	key, found := provider.keys[keyID]
+	stale := time.Since(provider.keysFetchedAt) > time.Minute
+	provider.mutex.Unlock()
+
+	if found {
+		return key, nil
+	}
+
+	if !stale {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+
+	if err := provider.fetchKeys(); err != nil {
+		return nil, err
+	}
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if key, found = provider.keys[keyID]; !found {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+
+	return key, nil
+}
+
+func (provider *Provider) fetchKeys() error {
+	metadata, err := provider.discover()
+	if err != nil {
+		return err
+	}
+
+	var keySet models.JSONWebKeySet
+	if err = getJSON(metadata.JWKSURI, &keySet); err != nil {
+		return fmt.Errorf("fetching the keys of the OpenID Connect provider %s: %w", provider.Name, err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, jsonWebKey := range keySet.Keys {
+		if jsonWebKey.Use != "" && jsonWebKey.Use != "sig" {
+			continue
+		}
+
+		// Keys of unsupported types are skipped, the provider may sign with the others
+		if key, err := parseJSONWebKey(jsonWebKey); err == nil {
+			keys[jsonWebKey.KeyID] = key
+		}
+	}
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	provider.keys = keys
+	provider.keysFetchedAt = time.Now()
+	return nil
+}
+
+func parseJSONWebKey(jsonWebKey models.JSONWebKey) (interface{}, error) {
+	switch jsonWebKey.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(jsonWebKey.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(jsonWebKey.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jsonWebKey.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jsonWebKey.Curve)
+		}
+
+		x, err := decodeBigInt(jsonWebKey.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(jsonWebKey.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", jsonWebKey.KeyType)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(bytes), nil
+}
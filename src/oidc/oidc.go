@@ -0,0 +1,187 @@
+package oidc
+
+import (
+	"api/src/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider is an OpenID Connect provider where users can sign in, like the company SSO
+type Provider struct {
+	Name         string
+	DiscoveryURL string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	mutex         sync.Mutex
+	metadata      *metadata
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+// metadata is the part of the discovery document used by the API
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// HTTPClient makes the requests to the providers
+var HTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Providers are the configured providers, by name
+var Providers = map[string]*Provider{}
+
+// Load creates the providers from the configuration. Their discovery documents are only fetched on the first use
+func Load() error {
+	Providers = map[string]*Provider{}
+
+	for name, discoveryURL := range config.OIDCDiscoveryURLs {
+		clientID := config.OIDCClientIDs[name]
+		if clientID == "" {
+			return fmt.Errorf("the OpenID Connect provider %s has no client ID", name)
+		}
+
+		Providers[name] = &Provider{
+			Name:         name,
+			DiscoveryURL: discoveryURL,
+			ClientID:     clientID,
+			ClientSecret: config.OIDCClientSecrets[name],
+			RedirectURL:  fmt.Sprintf("%s/auth/oidc/%s/callback", config.APIURL, url.PathEscape(name)),
+		}
+	}
+
+	return nil
+}
+
+// Names lists the configured providers
+func Names() []string {
+	names := make([]string, 0, len(Providers))
+	for name := range Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// AuthorizationURL builds the address where the user signs in on the provider
+func (provider *Provider) AuthorizationURL(state, nonce, codeChallenge string) (string, error) {
+	metadata, err := provider.discover()
+	if err != nil {
+		return "", err
+	}
+
+	authorizationURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	query := authorizationURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.ClientID)
+	query.Set("redirect_uri", provider.RedirectURL)
+	query.Set("scope", "openid email profile")
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authorizationURL.RawQuery = query.Encode()
+
+	return authorizationURL.String(), nil
+}
+
+// Exchange trades the authorization code sent back by the provider for the verified identity of the user
+func (provider *Provider) Exchange(code, codeVerifier, nonce string) (identity Identity, err error) {
+	metadata, err := provider.discover()
+	if err != nil {
+		return
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", provider.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	request, err := http.NewRequest(http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(provider.ClientSecret))
+
+	response, err := HTTPClient.Do(request)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err = json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&tokenResponse); err != nil {
+		return
+	}
+
+	if response.StatusCode != http.StatusOK {
+		err = fmt.Errorf("the provider refused the code: %s %s", tokenResponse.Error, tokenResponse.ErrorDescription)
+		return
+	}
+
+	if tokenResponse.IDToken == "" {
+		err = errors.New("the provider didn't send an ID token")
+		return
+	}
+
+	return provider.verifyIDToken(tokenResponse.IDToken, nonce)
+}
+
+// discover fetches the discovery document once, keeping it while the API runs
+func (provider *Provider) discover() (*metadata, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.metadata != nil {
+		return provider.metadata, nil
+	}
+
+	var discovered metadata
+	if err := getJSON(provider.DiscoveryURL, &discovered); err != nil {
+		return nil, fmt.Errorf("discovering the OpenID Connect provider %s: %w", provider.Name, err)
+	}
+
+	if discovered.Issuer == "" || discovered.AuthorizationEndpoint == "" || discovered.TokenEndpoint == "" || discovered.JWKSURI == "" {
+		return nil, fmt.Errorf("the discovery document of the OpenID Connect provider %s is incomplete", provider.Name)
+	}
+
+	provider.metadata = &discovered
+	return provider.metadata, nil
+}
+
+func getJSON(address string, value interface{}) error {
+	response, err := HTTPClient.Get(address)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", address, response.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(value)
+}
@@ -0,0 +1,44 @@
+// Package purge removes for good the users and posts deleted more than DELETED_RETENTION ago, when they can't be
+// restored anymore
+package purge
+
+import (
+	"api/src/config"
+	"api/src/repositories"
+	"context"
+	"log"
+	"time"
+)
+
+// Start purges the users and posts past DELETED_RETENTION in the background, right away and then every
+// DELETED_PURGE_INTERVAL
+func Start(users repositories.UserStore, posts repositories.PostStore) {
+	go func() {
+		Run(users, posts)
+		for range time.Tick(config.DeletedPurgeInterval) {
+			Run(users, posts)
+		}
+	}()
+}
+
+// Run purges once the users and posts deleted before DELETED_RETENTION ago. The posts of a purged user go with it
+func Run(users repositories.UserStore, posts repositories.PostStore) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.DeletedPurgeInterval)
+	defer cancel()
+
+	deletedBefore := time.Now().Add(-config.DeletedRetention)
+
+	purgedPosts, err := posts.PurgePosts(ctx, deletedBefore)
+	if err != nil {
+		log.Printf("purging the deleted posts: %v", err)
+	}
+
+	purgedUsers, err := users.Purge(ctx, deletedBefore)
+	if err != nil {
+		log.Printf("purging the deleted users: %v", err)
+	}
+
+	if purgedPosts > 0 || purgedUsers > 0 {
+		log.Printf("purged %d deleted users and %d deleted posts", purgedUsers, purgedPosts)
+	}
+}
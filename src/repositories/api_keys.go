@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// APIKeysRepository represents a repository of personal API keys
+type APIKeysRepository struct {
+	db database.DBTX
+}
+
+// NewAPIKeysRepository creates a new repository of API keys
+func NewAPIKeysRepository(db database.DBTX) *APIKeysRepository {
+	return &APIKeysRepository{db}
+}
+
+// Create inserts a new API key on the database
+func (apiKeysRepository APIKeysRepository) Create(ctx context.Context, apiKey models.APIKey) (apiKeyID uint64, err error) {
+	apiKeyID, err = database.InsertReturningID(ctx, apiKeysRepository.db,
+		"insert into api_keys (user_id, name, prefix, key_hash, scopes, expires_at) values (?, ?, ?, ?, ?, ?)",
+		apiKey.UserID,
+		apiKey.Name,
+		apiKey.Prefix,
+		apiKey.KeyHash,
+		strings.Join(apiKey.Scopes, " "),
+		apiKey.ExpiresAt,
+	)
+
+	return
+}
+
+// SearchByHash search an API key by its hash
+func (apiKeysRepository APIKeysRepository) SearchByHash(ctx context.Context, keyHash string) (apiKey models.APIKey, err error) {
+	lines, err := apiKeysRepository.db.QueryContext(ctx, `
+		select k.id, k.user_id, k.name, k.prefix, k.key_hash, k.scopes, k.expires_at, k.last_used_at, k.createdAt
+		from api_keys k inner join users u on u.id = k.user_id
+		where k.key_hash = ? and u.deleted_at is null`,
+		keyHash,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	if lines.Next() {
+		if apiKey, err = scanAPIKey(lines); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// SearchByUser gets all the API keys of an user
+func (apiKeysRepository APIKeysRepository) SearchByUser(ctx context.Context, userID uint64) (apiKeys []models.APIKey, err error) {
+	lines, err := apiKeysRepository.db.QueryContext(ctx, `
+		select k.id, k.user_id, k.name, k.prefix, k.key_hash, k.scopes, k.expires_at, k.last_used_at, k.createdAt
+		from api_keys k inner join users u on u.id = k.user_id
+		where k.user_id = ? and u.deleted_at is null
+		order by k.id`,
+		userID,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var apiKey models.APIKey
+
+		if apiKey, err = scanAPIKey(lines); err != nil {
+			return
+		}
+
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return
+}
+
+// Touch records that the API key was used now
+func (apiKeysRepository APIKeysRepository) Touch(ctx context.Context, apiKeyID uint64) (err error) {
+	statement, err := apiKeysRepository.db.PrepareContext(ctx, "update api_keys set last_used_at = ? where id = ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, time.Now(), apiKeyID); err != nil {
+		return
+	}
+
+	return
+}
+
+// Delete deletes an API key of the user
+func (apiKeysRepository APIKeysRepository) Delete(ctx context.Context, userID, apiKeyID uint64) (deleted bool, err error) {
+	statement, err := apiKeysRepository.db.PrepareContext(ctx, "delete from api_keys where id = ? and user_id = ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, apiKeyID, userID)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	deleted = rowsAffected == 1
+
+	return
+}
+
+func scanAPIKey(lines *sql.Rows) (apiKey models.APIKey, err error) {
+	var scopes string
+
+	if err = lines.Scan(
+		&apiKey.ID,
+		&apiKey.UserID,
+		&apiKey.Name,
+		&apiKey.Prefix,
+		&apiKey.KeyHash,
+		&scopes,
+		&apiKey.ExpiresAt,
+		&apiKey.LastUsedAt,
+		&apiKey.CreatedAt,
+	); err != nil {
+		return
+	}
+
+	apiKey.Scopes = strings.Fields(scopes)
+	return
+}
@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+	"time"
+)
+
+// EmailVerificationsRepository represents a repository of email verification tokens
+type EmailVerificationsRepository struct {
+	db database.DBTX
+}
+
+// NewEmailVerificationsRepository creates a new repository of email verification tokens
+func NewEmailVerificationsRepository(db database.DBTX) *EmailVerificationsRepository {
+	return &EmailVerificationsRepository{db}
+}
+
+// Create inserts a new email verification token on the database
+func (emailVerificationsRepository EmailVerificationsRepository) Create(ctx context.Context, emailVerification models.EmailVerification) (err error) {
+	statement, err := emailVerificationsRepository.db.PrepareContext(ctx,
+		"insert into email_verifications (user_id, email, token_hash, expires_at) values (?, ?, ?, ?)",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx,
+		emailVerification.UserID,
+		emailVerification.Email,
+		emailVerification.TokenHash,
+		emailVerification.ExpiresAt,
+	); err != nil {
+		return
+	}
+
+	return
+}
+
+// SearchByHash search an email verification token by its hash
+func (emailVerificationsRepository EmailVerificationsRepository) SearchByHash(ctx context.Context, tokenHash string) (emailVerification models.EmailVerification, err error) {
+	line, err := emailVerificationsRepository.db.QueryContext(ctx, `
+		select id, user_id, email, token_hash, expires_at, used_at, createdAt
+		from email_verifications
+		where token_hash = ?`,
+		tokenHash,
+	)
+	if err != nil {
+		return
+	}
+	defer line.Close()
+
+	if line.Next() {
+		if err = line.Scan(
+			&emailVerification.ID,
+			&emailVerification.UserID,
+			&emailVerification.Email,
+			&emailVerification.TokenHash,
+			&emailVerification.ExpiresAt,
+			&emailVerification.UsedAt,
+			&emailVerification.CreatedAt,
+		); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// SearchLatestPending search the last email verification of the user that wasn't used, expired or not
+func (emailVerificationsRepository EmailVerificationsRepository) SearchLatestPending(ctx context.Context, userID uint64) (emailVerification models.EmailVerification, err error) {
+	line, err := emailVerificationsRepository.db.QueryContext(ctx, `
+		select id, user_id, email, token_hash, expires_at, used_at, createdAt
+		from email_verifications
+		where user_id = ? and used_at is null
+		order by id desc
+		limit 1`,
+		userID,
+	)
+	if err != nil {
+		return
+	}
+	defer line.Close()
+
+	if line.Next() {
+		if err = line.Scan(
+			&emailVerification.ID,
+			&emailVerification.UserID,
+			&emailVerification.Email,
+			&emailVerification.TokenHash,
+			&emailVerification.ExpiresAt,
+			&emailVerification.UsedAt,
+			&emailVerification.CreatedAt,
+		); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Use marks an email verification token as used, reporting false if it was already used
+func (emailVerificationsRepository EmailVerificationsRepository) Use(ctx context.Context, emailVerificationID uint64) (used bool, err error) {
+	statement, err := emailVerificationsRepository.db.PrepareContext(ctx,
+		"update email_verifications set used_at = ? where id = ? and used_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, time.Now(), emailVerificationID)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	used = rowsAffected == 1
+
+	return
+}
+
+// UseAllFromUser invalidates every pending email verification token of the user
+func (emailVerificationsRepository EmailVerificationsRepository) UseAllFromUser(ctx context.Context, userID uint64) (err error) {
+	statement, err := emailVerificationsRepository.db.PrepareContext(ctx,
+		"update email_verifications set used_at = ? where user_id = ? and used_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, time.Now(), userID); err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ImpersonationsRepository represents the audit trail of the impersonations
+type ImpersonationsRepository struct {
+	db database.DBTX
+}
+
+// NewImpersonationsRepository creates a new repository of impersonations
+func NewImpersonationsRepository(db database.DBTX) *ImpersonationsRepository {
+	return &ImpersonationsRepository{db}
+}
+
+// Create inserts a new impersonation on the database
+func (impersonationsRepository ImpersonationsRepository) Create(ctx context.Context, impersonation models.Impersonation) (impersonationID uint64, err error) {
+	impersonationID, err = database.InsertReturningID(ctx, impersonationsRepository.db, `
+		insert into impersonations (actor_id, target_id, reason, read_only, token_id, started_at, expires_at)
+		values (?, ?, ?, ?, ?, ?, ?)`,
+		impersonation.ActorID,
+		impersonation.TargetID,
+		impersonation.Reason,
+		impersonation.ReadOnly,
+		impersonation.TokenID,
+		impersonation.StartedAt,
+		impersonation.ExpiresAt,
+	)
+
+	return
+}
+
+// SearchByID search an impersonation by its ID
+func (impersonationsRepository ImpersonationsRepository) SearchByID(ctx context.Context, impersonationID uint64) (impersonation models.Impersonation, err error) {
+	return impersonationsRepository.searchOne(ctx, "id = ?", impersonationID)
+}
+
+// SearchByTokenID search the impersonation of a token
+func (impersonationsRepository ImpersonationsRepository) SearchByTokenID(ctx context.Context, tokenID string) (impersonation models.Impersonation, err error) {
+	return impersonationsRepository.searchOne(ctx, "token_id = ?", tokenID)
+}
+
+func (impersonationsRepository ImpersonationsRepository) searchOne(ctx context.Context, condition string, value interface{}) (impersonation models.Impersonation, err error) {
+	lines, err := impersonationsRepository.db.QueryContext(ctx, `
+		select id, actor_id, target_id, reason, read_only, token_id, started_at, expires_at, ended_at
+		from impersonations
+		where `+condition,
+		value,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	if lines.Next() {
+		if impersonation, err = scanImpersonation(lines); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Search gets the latest impersonations
+func (impersonationsRepository ImpersonationsRepository) Search(ctx context.Context, limit int) (impersonations []models.Impersonation, err error) {
+	lines, err := impersonationsRepository.db.QueryContext(ctx, `
+		select id, actor_id, target_id, reason, read_only, token_id, started_at, expires_at, ended_at
+		from impersonations
+		order by id desc
+		limit ?`,
+		limit,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var impersonation models.Impersonation
+
+		if impersonation, err = scanImpersonation(lines); err != nil {
+			return
+		}
+
+		impersonations = append(impersonations, impersonation)
+	}
+
+	return
+}
+
+// End records that the impersonation ended, if it didn't already
+func (impersonationsRepository ImpersonationsRepository) End(ctx context.Context, impersonationID uint64) (err error) {
+	statement, err := impersonationsRepository.db.PrepareContext(ctx,
+		"update impersonations set ended_at = ? where id = ? and ended_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, time.Now(), impersonationID); err != nil {
+		return
+	}
+
+	return
+}
+
+// CreateRequest records a request made with the impersonation token
+func (impersonationsRepository ImpersonationsRepository) CreateRequest(ctx context.Context, tokenID, method, path string, status int) (err error) {
+	// The values go on the values list, where every database knows their types from the columns
+	statement, err := impersonationsRepository.db.PrepareContext(ctx, `
+		insert into impersonated_requests (impersonation_id, method, path, status, createdAt)
+		values ((select id from impersonations where token_id = ?), ?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, tokenID, method, path, status, time.Now()); err != nil {
+		return
+	}
+
+	return
+}
+
+// SearchRequests gets the requests made during an impersonation
+func (impersonationsRepository ImpersonationsRepository) SearchRequests(ctx context.Context, impersonationID uint64) (requests []models.ImpersonatedRequest, err error) {
+	lines, err := impersonationsRepository.db.QueryContext(ctx, `
+		select id, impersonation_id, method, path, status, createdAt
+		from impersonated_requests
+		where impersonation_id = ?
+		order by id`,
+		impersonationID,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var request models.ImpersonatedRequest
+
+		if err = lines.Scan(
+			&request.ID,
+			&request.ImpersonationID,
+			&request.Method,
+			&request.Path,
+			&request.Status,
+			&request.CreatedAt,
+		); err != nil {
+			return
+		}
+
+		requests = append(requests, request)
+	}
+
+	return
+}
+
+func scanImpersonation(lines *sql.Rows) (impersonation models.Impersonation, err error) {
+	err = lines.Scan(
+		&impersonation.ID,
+		&impersonation.ActorID,
+		&impersonation.TargetID,
+		&impersonation.Reason,
+		&impersonation.ReadOnly,
+		&impersonation.TokenID,
+		&impersonation.StartedAt,
+		&impersonation.ExpiresAt,
+		&impersonation.EndedAt,
+	)
+	return
+}
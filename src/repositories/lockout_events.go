@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+)
+
+// LockoutEventsRepository represents a repository of login lockout events
+type LockoutEventsRepository struct {
+	db database.DBTX
+}
+
+// NewLockoutEventsRepository creates a new repository of lockout events
+func NewLockoutEventsRepository(db database.DBTX) *LockoutEventsRepository {
+	return &LockoutEventsRepository{db}
+}
+
+// Create inserts a new lockout event on the database
+func (lockoutEventsRepository LockoutEventsRepository) Create(ctx context.Context, lockoutEvent models.LockoutEvent) (err error) {
+	statement, err := lockoutEventsRepository.db.PrepareContext(ctx,
+		"insert into lockout_events (kind, identifier, failures, locked_until) values (?, ?, ?, ?)",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx,
+		lockoutEvent.Kind,
+		lockoutEvent.Identifier,
+		lockoutEvent.Failures,
+		lockoutEvent.LockedUntil,
+	); err != nil {
+		return
+	}
+
+	return
+}
+
+// Search gets the most recent lockout events
+func (lockoutEventsRepository LockoutEventsRepository) Search(ctx context.Context, limit int) (lockoutEvents []models.LockoutEvent, err error) {
+	lines, err := lockoutEventsRepository.db.QueryContext(ctx, `
+		select id, kind, identifier, failures, locked_until, createdAt
+		from lockout_events
+		order by id desc
+		limit ?`,
+		limit,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var lockoutEvent models.LockoutEvent
+
+		if err = lines.Scan(
+			&lockoutEvent.ID,
+			&lockoutEvent.Kind,
+			&lockoutEvent.Identifier,
+			&lockoutEvent.Failures,
+			&lockoutEvent.LockedUntil,
+			&lockoutEvent.CreatedAt,
+		); err != nil {
+			return
+		}
+
+		lockoutEvents = append(lockoutEvents, lockoutEvent)
+	}
+
+	return
+}
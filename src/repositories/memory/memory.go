@@ -0,0 +1,81 @@
+// Package memory keeps users and posts in memory, with the same behavior as the repositories on the
+// database. It lets the handlers run on tests without MySQL
+package memory
+
+import (
+	"api/src/models"
+	"api/src/repositories"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDuplicate is returned when the nick or the email of an user is already in use, like the unique keys of the database
+var ErrDuplicate = errors.New("the nick or the email is already in use by another user")
+
+type user struct {
+	models.User
+	tokensValidAfter *time.Time
+	totp             models.TOTP
+	deletedAt        *time.Time
+}
+
+type follower struct {
+	userID     uint64
+	followerID uint64
+}
+
+// Store keeps the users, their followers and their posts, safe to use from many goroutines
+type Store struct {
+	mutex      sync.RWMutex
+	lastUserID uint64
+	lastPostID uint64
+	users      map[uint64]*user
+	followers  map[follower]bool
+	posts      map[uint64]*models.Post
+}
+
+// New creates an empty store
+func New() *Store {
+	return &Store{
+		users:     map[uint64]*user{},
+		followers: map[follower]bool{},
+		posts:     map[uint64]*models.Post{},
+	}
+}
+
+// Users gives the users of the store
+func (store *Store) Users() *UserStore {
+	return &UserStore{store}
+}
+
+// Posts gives the posts of the store
+func (store *Store) Posts() *PostStore {
+	return &PostStore{store}
+}
+
+// inUse reports if another user than the given one has the nick or the email
+func (store *Store) inUse(ID uint64, nick, email string) bool {
+	for _, savedUser := range store.users {
+		if savedUser.ID == ID {
+			continue
+		}
+
+		if strings.EqualFold(savedUser.Nick, nick) || strings.EqualFold(savedUser.Email, email) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// now is the current time with the precision of the database columns
+func now() time.Time {
+	return time.Now().Truncate(time.Second)
+}
+
+var (
+	_ repositories.UserStore = (*UserStore)(nil)
+	_ repositories.PostStore = (*PostStore)(nil)
+)
@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"api/src/repositories"
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// PostStore keeps posts in memory
+type PostStore struct {
+	store *Store
+}
+
+// WithTx gives the same store, like UserStore.WithTx
+func (postStore PostStore) WithTx(tx database.DBTX) repositories.PostStore {
+	return postStore
+}
+
+// CreatePost inserts a post on the store
+func (postStore PostStore) CreatePost(ctx context.Context, post models.Post) (postID uint64, err error) {
+	store := postStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, found := store.users[post.AuthorID]; !found {
+		err = errors.New("the author of the post doesn't exist")
+		return
+	}
+
+	store.lastPostID++
+	postID = store.lastPostID
+
+	post.ID = postID
+	post.AuthorNick = ""
+	post.Likes = 0
+	post.CreatedAt = now()
+	post.Version = 1
+	post.DeletedAt = nil
+	store.posts[postID] = &post
+
+	return
+}
+
+// SearchByID search a post by its ID
+func (postStore PostStore) SearchByID(ctx context.Context, postID uint64) (post models.Post, err error) {
+	store := postStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	if savedPost, found := store.posts[postID]; found && store.visible(savedPost) {
+		post = store.withAuthor(savedPost)
+	}
+
+	return
+}
+
+// Search gets all posts from the user and from those that he follows, the newest first
+func (postStore PostStore) Search(ctx context.Context, userID uint64) (posts []models.Post, err error) {
+	store := postStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, savedPost := range store.posts {
+		if !store.visible(savedPost) {
+			continue
+		}
+
+		if savedPost.AuthorID == userID || store.followers[follower{savedPost.AuthorID, userID}] {
+			posts = append(posts, store.withAuthor(savedPost))
+		}
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		if posts[i].CreatedAt.Equal(posts[j].CreatedAt) {
+			return posts[i].ID > posts[j].ID
+		}
+
+		return posts[i].CreatedAt.After(posts[j].CreatedAt)
+	})
+	return
+}
+
+// SearchPostsByUser get all posts from an user
+func (postStore PostStore) SearchPostsByUser(ctx context.Context, userID uint64) (posts []models.Post, err error) {
+	store := postStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, savedPost := range store.posts {
+		if savedPost.AuthorID == userID && store.visible(savedPost) {
+			posts = append(posts, store.withAuthor(savedPost))
+		}
+	}
+
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	return
+}
+
+// UpdatePost update the title and the content of the post when it is still at the version
+func (postStore PostStore) UpdatePost(ctx context.Context, postID, version uint64, post models.Post) (updated bool, err error) {
+	store := postStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if savedPost, found := store.posts[postID]; found && savedPost.DeletedAt == nil && savedPost.Version == version {
+		savedPost.Title = post.Title
+		savedPost.Content = post.Content
+		savedPost.Version++
+		updated = true
+	}
+
+	return
+}
+
+// DeletePost marks a post as deleted, hiding it until it is restored or purged
+func (postStore PostStore) DeletePost(ctx context.Context, postID uint64) (err error) {
+	store := postStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if savedPost, found := store.posts[postID]; found && savedPost.DeletedAt == nil {
+		deletedAt := now()
+		savedPost.DeletedAt = &deletedAt
+	}
+
+	return
+}
+
+// SearchDeletedPost search a deleted post by its ID, with when it was deleted
+func (postStore PostStore) SearchDeletedPost(ctx context.Context, postID uint64) (post models.Post, err error) {
+	store := postStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	if savedPost, found := store.posts[postID]; found && savedPost.DeletedAt != nil {
+		post.ID = savedPost.ID
+		post.AuthorID = savedPost.AuthorID
+		post.DeletedAt = copyTime(savedPost.DeletedAt)
+	}
+
+	return
+}
+
+// RestorePost brings back the post when it was deleted after deletedSince, reporting false when there is no such post
+func (postStore PostStore) RestorePost(ctx context.Context, postID uint64, deletedSince time.Time) (restored bool, err error) {
+	store := postStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if savedPost, found := store.posts[postID]; found && savedPost.DeletedAt != nil && !savedPost.DeletedAt.Before(deletedSince) {
+		savedPost.DeletedAt = nil
+		restored = true
+	}
+
+	return
+}
+
+// PurgePosts removes for good the posts deleted before deletedBefore
+func (postStore PostStore) PurgePosts(ctx context.Context, deletedBefore time.Time) (purged int64, err error) {
+	store := postStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for postID, savedPost := range store.posts {
+		if savedPost.DeletedAt != nil && savedPost.DeletedAt.Before(deletedBefore) {
+			delete(store.posts, postID)
+			purged++
+		}
+	}
+
+	return
+}
+
+// Like adds one like on a post
+func (postStore PostStore) Like(ctx context.Context, postID uint64) (err error) {
+	store := postStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if savedPost, found := store.posts[postID]; found && savedPost.DeletedAt == nil {
+		savedPost.Likes++
+	}
+
+	return
+}
+
+// UnLike removes one like from a post, never going below zero
+func (postStore PostStore) UnLike(ctx context.Context, postID uint64) (err error) {
+	store := postStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if savedPost, found := store.posts[postID]; found && savedPost.DeletedAt == nil && savedPost.Likes > 0 {
+		savedPost.Likes--
+	}
+
+	return
+}
+
+// visible reports if the post and its author weren't deleted
+func (store *Store) visible(savedPost *models.Post) bool {
+	_, authorFound := store.activeUser(savedPost.AuthorID)
+	return savedPost.DeletedAt == nil && authorFound
+}
+
+// withAuthor copies the post with the nick of its author, like the join on the database
+func (store *Store) withAuthor(savedPost *models.Post) models.Post {
+	post := *savedPost
+	if author, found := store.users[post.AuthorID]; found {
+		post.AuthorNick = author.Nick
+	}
+
+	return post
+}
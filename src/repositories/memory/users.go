@@ -0,0 +1,454 @@
+package memory
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"api/src/repositories"
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UserStore keeps users in memory
+type UserStore struct {
+	store *Store
+}
+
+// WithTx gives the same store: it has no transactions, so the writes of a unit of work that fails aren't undone
+func (userStore UserStore) WithTx(tx database.DBTX) repositories.UserStore {
+	return userStore
+}
+
+// Create insert a new user on the store
+func (userStore UserStore) Create(ctx context.Context, newUser models.User) (userID uint64, err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.inUse(0, newUser.Nick, newUser.Email) {
+		err = ErrDuplicate
+		return
+	}
+
+	store.lastUserID++
+	userID = store.lastUserID
+
+	newUser.ID = userID
+	newUser.EmailVerifiedAt = nil
+	newUser.CreatedAt = now()
+	newUser.Version = 1
+	store.users[userID] = &user{User: newUser}
+
+	return
+}
+
+// Search for users given by a part of the name or of the nick
+func (userStore UserStore) Search(ctx context.Context, nameOrNick string) (users []models.User, err error) {
+	store := userStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	nameOrNick = strings.ToLower(nameOrNick)
+	for _, savedUser := range store.sortedUsers() {
+		if strings.Contains(strings.ToLower(savedUser.Name), nameOrNick) ||
+			strings.Contains(strings.ToLower(savedUser.Nick), nameOrNick) {
+			users = append(users, savedUser.public())
+		}
+	}
+
+	return
+}
+
+// SerachByID search a user by its ID
+func (userStore UserStore) SerachByID(ctx context.Context, ID uint64) (foundUser models.User, err error) {
+	store := userStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	if savedUser, found := store.activeUser(ID); found {
+		foundUser = savedUser.public()
+		foundUser.EmailVerifiedAt = copyTime(savedUser.EmailVerifiedAt)
+		foundUser.Version = savedUser.Version
+	}
+
+	return
+}
+
+// SearchByEmail searchs a user by its email, giving only its ID, email, password and verification
+func (userStore UserStore) SearchByEmail(ctx context.Context, email string) (foundUser models.User, err error) {
+	store := userStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, savedUser := range store.users {
+		if savedUser.deletedAt == nil && strings.EqualFold(savedUser.Email, email) {
+			foundUser.ID = savedUser.ID
+			foundUser.Email = savedUser.Email
+			foundUser.Password = savedUser.Password
+			foundUser.EmailVerifiedAt = copyTime(savedUser.EmailVerifiedAt)
+			return
+		}
+	}
+
+	return
+}
+
+// NickExists reports if the nick is already used by an user, deleted or not
+func (userStore UserStore) NickExists(ctx context.Context, nick string) (exists bool, err error) {
+	store := userStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, savedUser := range store.users {
+		if strings.EqualFold(savedUser.Nick, nick) {
+			exists = true
+			return
+		}
+	}
+
+	return
+}
+
+// EmailExists reports if the email is already used by an user, deleted or not
+func (userStore UserStore) EmailExists(ctx context.Context, email string) (exists bool, err error) {
+	store := userStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, savedUser := range store.users {
+		if strings.EqualFold(savedUser.Email, email) {
+			exists = true
+			return
+		}
+	}
+
+	return
+}
+
+// Update the name, the nick and the email of the user when it is still at the version
+func (userStore UserStore) Update(ctx context.Context, ID, version uint64, changes models.User) (updated bool, err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	savedUser, found := store.activeUser(ID)
+	if !found || savedUser.Version != version {
+		return
+	}
+
+	if store.inUse(ID, changes.Nick, changes.Email) {
+		err = ErrDuplicate
+		return
+	}
+
+	savedUser.Name = changes.Name
+	savedUser.Nick = changes.Nick
+	savedUser.Email = changes.Email
+	savedUser.Version++
+	updated = true
+
+	return
+}
+
+// Delete marks the user as deleted, hiding it with its posts and follows until it is restored or purged
+func (userStore UserStore) Delete(ctx context.Context, ID uint64) (err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if savedUser, found := store.activeUser(ID); found {
+		deletedAt := now()
+		savedUser.deletedAt = &deletedAt
+	}
+
+	return
+}
+
+// Restore brings back the user when it was deleted after deletedSince, reporting false when there is no such user
+func (userStore UserStore) Restore(ctx context.Context, ID uint64, deletedSince time.Time) (restored bool, err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if savedUser, found := store.users[ID]; found && savedUser.deletedAt != nil && !savedUser.deletedAt.Before(deletedSince) {
+		savedUser.deletedAt = nil
+		restored = true
+	}
+
+	return
+}
+
+// Purge removes for good the users deleted before deletedBefore, with their followers and posts
+func (userStore UserStore) Purge(ctx context.Context, deletedBefore time.Time) (purged int64, err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for ID, savedUser := range store.users {
+		if savedUser.deletedAt == nil || !savedUser.deletedAt.Before(deletedBefore) {
+			continue
+		}
+
+		delete(store.users, ID)
+		purged++
+
+		for relation := range store.followers {
+			if relation.userID == ID || relation.followerID == ID {
+				delete(store.followers, relation)
+			}
+		}
+
+		for postID, post := range store.posts {
+			if post.AuthorID == ID {
+				delete(store.posts, postID)
+			}
+		}
+	}
+
+	return
+}
+
+// FollowUser permits an user to follow another, doing nothing when one of them doesn't exist
+func (userStore UserStore) FollowUser(ctx context.Context, userID, followerID uint64) (err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	_, userFound := store.users[userID]
+	_, followerFound := store.users[followerID]
+	if userFound && followerFound {
+		store.followers[follower{userID, followerID}] = true
+	}
+
+	return
+}
+
+// UnFollowUser stops an user from following another
+func (userStore UserStore) UnFollowUser(ctx context.Context, userID, followerID uint64) (err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.followers, follower{userID, followerID})
+
+	return
+}
+
+// SearchFollowers gets all followers from a user given its ID
+func (userStore UserStore) SearchFollowers(ctx context.Context, userID uint64) (users []models.User, err error) {
+	store := userStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, savedUser := range store.sortedUsers() {
+		if store.followers[follower{userID, savedUser.ID}] {
+			users = append(users, savedUser.public())
+		}
+	}
+
+	return
+}
+
+// SearchFollowing gets all users followed by a user given its ID
+func (userStore UserStore) SearchFollowing(ctx context.Context, userID uint64) (users []models.User, err error) {
+	store := userStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, savedUser := range store.sortedUsers() {
+		if store.followers[follower{savedUser.ID, userID}] {
+			users = append(users, savedUser.public())
+		}
+	}
+
+	return
+}
+
+// SearchPassword gets the hashed password of the user
+func (userStore UserStore) SearchPassword(ctx context.Context, userID uint64) (hashedPassword string, err error) {
+	store := userStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	if savedUser, found := store.activeUser(userID); found {
+		hashedPassword = savedUser.Password
+	}
+
+	return
+}
+
+// UpdatePassword updates the password of the user, reporting false when the user doesn't exist or was deleted
+func (userStore UserStore) UpdatePassword(ctx context.Context, userID uint64, hashedPassword string) (updated bool, err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if savedUser, found := store.activeUser(userID); found {
+		savedUser.Password = hashedPassword
+		updated = true
+	}
+
+	return
+}
+
+// SearchTokensValidAfter gets since when the user's tokens are accepted, reporting if the user exists and wasn't deleted
+func (userStore UserStore) SearchTokensValidAfter(ctx context.Context, userID uint64) (exists bool, tokensValidAfter *time.Time, err error) {
+	store := userStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	savedUser, exists := store.activeUser(userID)
+	if exists {
+		tokensValidAfter = copyTime(savedUser.tokensValidAfter)
+	}
+
+	return
+}
+
+// RevokeTokens makes every token issued to the user until now invalid
+func (userStore UserStore) RevokeTokens(ctx context.Context, userID uint64) (tokensValidAfter time.Time, err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	tokensValidAfter = time.Now().Truncate(time.Microsecond)
+	if savedUser, found := store.users[userID]; found {
+		savedUser.tokensValidAfter = copyTime(&tokensValidAfter)
+	}
+
+	return
+}
+
+// SearchTOTP gets the two-factor authentication settings of an user
+func (userStore UserStore) SearchTOTP(ctx context.Context, userID uint64) (totp models.TOTP, err error) {
+	store := userStore.store
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	if savedUser, found := store.activeUser(userID); found {
+		totp = savedUser.totp
+	}
+
+	return
+}
+
+// SaveTOTPSecret stores a new TOTP secret, still disabled until the user confirms it
+func (userStore UserStore) SaveTOTPSecret(ctx context.Context, userID uint64, secret string) (err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if savedUser, found := store.users[userID]; found {
+		savedUser.totp = models.TOTP{Secret: secret}
+	}
+
+	return
+}
+
+// EnableTOTP turns the two-factor authentication on for the user
+func (userStore UserStore) EnableTOTP(ctx context.Context, userID uint64) (err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if savedUser, found := store.users[userID]; found {
+		savedUser.totp.Enabled = true
+	}
+
+	return
+}
+
+// DisableTOTP turns the two-factor authentication off and forgets the secret
+func (userStore UserStore) DisableTOTP(ctx context.Context, userID uint64) (err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if savedUser, found := store.users[userID]; found {
+		savedUser.totp = models.TOTP{}
+	}
+
+	return
+}
+
+// UseTOTPStep records the time step of an accepted TOTP code, reporting false if it (or a later one) was already used
+func (userStore UserStore) UseTOTPStep(ctx context.Context, userID, step uint64) (used bool, err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if savedUser, found := store.users[userID]; found && savedUser.totp.LastStep < step {
+		savedUser.totp.LastStep = step
+		used = true
+	}
+
+	return
+}
+
+// VerifyEmail sets the email of the user as verified, replacing the current one when it changed
+func (userStore UserStore) VerifyEmail(ctx context.Context, userID uint64, email string) (err error) {
+	store := userStore.store
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	savedUser, found := store.users[userID]
+	if !found {
+		return
+	}
+
+	if store.inUse(userID, "", email) {
+		err = ErrDuplicate
+		return
+	}
+
+	verifiedAt := time.Now()
+	savedUser.Email = email
+	savedUser.EmailVerifiedAt = &verifiedAt
+	savedUser.Version++
+
+	return
+}
+
+// sortedUsers lists the users that weren't deleted by ID, the order the database gives them
+func (store *Store) sortedUsers() []*user {
+	users := make([]*user, 0, len(store.users))
+	for _, savedUser := range store.users {
+		if savedUser.deletedAt == nil {
+			users = append(users, savedUser)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
+
+// activeUser finds the user when it wasn't deleted
+func (store *Store) activeUser(ID uint64) (savedUser *user, found bool) {
+	savedUser, found = store.users[ID]
+	if found && savedUser.deletedAt != nil {
+		return nil, false
+	}
+
+	return
+}
+
+// public gives the columns of the user selected on searches, without the password
+func (savedUser *user) public() models.User {
+	return models.User{
+		ID:        savedUser.ID,
+		Name:      savedUser.Name,
+		Nick:      savedUser.Nick,
+		Email:     savedUser.Email,
+		CreatedAt: savedUser.CreatedAt,
+	}
+}
+
+func copyTime(value *time.Time) *time.Time {
+	if value == nil {
+		return nil
+	}
+
+	copied := *value
+	return &copied
+}
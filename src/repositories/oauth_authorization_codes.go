@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+	"strings"
+	"time"
+)
+
+// OAuthAuthorizationCodesRepository represents a repository of OAuth authorization codes
+type OAuthAuthorizationCodesRepository struct {
+	db database.DBTX
+}
+
+// NewOAuthAuthorizationCodesRepository creates a new repository of OAuth authorization codes
+func NewOAuthAuthorizationCodesRepository(db database.DBTX) *OAuthAuthorizationCodesRepository {
+	return &OAuthAuthorizationCodesRepository{db}
+}
+
+// Create inserts a new authorization code on the database
+func (codesRepository OAuthAuthorizationCodesRepository) Create(ctx context.Context, code models.OAuthAuthorizationCode) (err error) {
+	statement, err := codesRepository.db.PrepareContext(ctx, `
+		insert into oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+		values (?, ?, ?, ?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx,
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		strings.Join(code.Scopes, " "),
+		code.CodeChallenge,
+		code.ExpiresAt,
+	); err != nil {
+		return
+	}
+
+	return
+}
+
+// SearchByHash search an authorization code by its hash
+func (codesRepository OAuthAuthorizationCodesRepository) SearchByHash(ctx context.Context, codeHash string) (code models.OAuthAuthorizationCode, err error) {
+	lines, err := codesRepository.db.QueryContext(ctx, `
+		select id, code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, session_id, expires_at, used_at
+		from oauth_authorization_codes
+		where code_hash = ?`,
+		codeHash,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	if lines.Next() {
+		var scopes string
+
+		if err = lines.Scan(
+			&code.ID,
+			&code.CodeHash,
+			&code.ClientID,
+			&code.UserID,
+			&code.RedirectURI,
+			&scopes,
+			&code.CodeChallenge,
+			&code.SessionID,
+			&code.ExpiresAt,
+			&code.UsedAt,
+		); err != nil {
+			return
+		}
+
+		code.Scopes = strings.Fields(scopes)
+	}
+
+	return
+}
+
+// Use marks an authorization code as exchanged for the session, reporting false if it was already used
+func (codesRepository OAuthAuthorizationCodesRepository) Use(ctx context.Context, codeID uint64, sessionID string) (used bool, err error) {
+	statement, err := codesRepository.db.PrepareContext(ctx,
+		"update oauth_authorization_codes set used_at = ?, session_id = ? where id = ? and used_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, time.Now(), sessionID, codeID)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	used = rowsAffected == 1
+
+	return
+}
+
+// DeleteExpired deletes the codes that can't be exchanged anymore
+func (codesRepository OAuthAuthorizationCodesRepository) DeleteExpired(ctx context.Context) (err error) {
+	statement, err := codesRepository.db.PrepareContext(ctx, "delete from oauth_authorization_codes where expires_at < ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, time.Now()); err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// OAuthClientsRepository represents a repository of OAuth clients
+type OAuthClientsRepository struct {
+	db database.DBTX
+}
+
+// NewOAuthClientsRepository creates a new repository of OAuth clients
+func NewOAuthClientsRepository(db database.DBTX) *OAuthClientsRepository {
+	return &OAuthClientsRepository{db}
+}
+
+// Create inserts a new OAuth client on the database
+func (oauthClientsRepository OAuthClientsRepository) Create(ctx context.Context, client models.OAuthClient) (ID uint64, err error) {
+	ID, err = database.InsertReturningID(ctx, oauthClientsRepository.db,
+		"insert into oauth_clients (client_id, user_id, name, redirect_uris, confidential, secret_hash) values (?, ?, ?, ?, ?, ?)",
+		client.ClientID,
+		client.UserID,
+		client.Name,
+		strings.Join(client.RedirectURIs, " "),
+		client.Confidential,
+		client.SecretHash,
+	)
+
+	return
+}
+
+// SearchByClientID search an OAuth client by its client ID
+func (oauthClientsRepository OAuthClientsRepository) SearchByClientID(ctx context.Context, clientID string) (client models.OAuthClient, err error) {
+	lines, err := oauthClientsRepository.db.QueryContext(ctx, `
+		select id, client_id, user_id, name, redirect_uris, confidential, secret_hash, createdAt
+		from oauth_clients
+		where client_id = ?`,
+		clientID,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	if lines.Next() {
+		if client, err = scanOAuthClient(lines); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// SearchByUser gets all the OAuth clients registered by an user
+func (oauthClientsRepository OAuthClientsRepository) SearchByUser(ctx context.Context, userID uint64) (clients []models.OAuthClient, err error) {
+	lines, err := oauthClientsRepository.db.QueryContext(ctx, `
+		select id, client_id, user_id, name, redirect_uris, confidential, secret_hash, createdAt
+		from oauth_clients
+		where user_id = ?
+		order by id`,
+		userID,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var client models.OAuthClient
+
+		if client, err = scanOAuthClient(lines); err != nil {
+			return
+		}
+
+		clients = append(clients, client)
+	}
+
+	return
+}
+
+// Delete deletes an OAuth client registered by the user
+func (oauthClientsRepository OAuthClientsRepository) Delete(ctx context.Context, userID uint64, clientID string) (deleted bool, err error) {
+	statement, err := oauthClientsRepository.db.PrepareContext(ctx, "delete from oauth_clients where client_id = ? and user_id = ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, clientID, userID)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	deleted = rowsAffected == 1
+
+	return
+}
+
+func scanOAuthClient(lines *sql.Rows) (client models.OAuthClient, err error) {
+	var redirectURIs string
+
+	if err = lines.Scan(
+		&client.ID,
+		&client.ClientID,
+		&client.UserID,
+		&client.Name,
+		&redirectURIs,
+		&client.Confidential,
+		&client.SecretHash,
+		&client.CreatedAt,
+	); err != nil {
+		return
+	}
+
+	client.RedirectURIs = strings.Fields(redirectURIs)
+	return
+}
@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// OAuthGrantsRepository represents a repository of the scopes granted by users to OAuth clients
+type OAuthGrantsRepository struct {
+	db database.DBTX
+}
+
+// NewOAuthGrantsRepository creates a new repository of OAuth grants
+func NewOAuthGrantsRepository(db database.DBTX) *OAuthGrantsRepository {
+	return &OAuthGrantsRepository{db}
+}
+
+// Search search the grant of an user to a client
+func (oauthGrantsRepository OAuthGrantsRepository) Search(ctx context.Context, userID uint64, clientID string) (grant models.OAuthGrant, found bool, err error) {
+	lines, err := oauthGrantsRepository.db.QueryContext(ctx, `
+		select g.user_id, g.client_id, c.name, g.scopes, g.createdAt
+		from oauth_grants g inner join oauth_clients c on c.client_id = g.client_id
+		where g.user_id = ? and g.client_id = ?`,
+		userID, clientID,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	if lines.Next() {
+		if grant, err = scanOAuthGrant(lines); err != nil {
+			return
+		}
+		found = true
+	}
+
+	return
+}
+
+// SearchByUser gets all the grants of an user
+func (oauthGrantsRepository OAuthGrantsRepository) SearchByUser(ctx context.Context, userID uint64) (grants []models.OAuthGrant, err error) {
+	lines, err := oauthGrantsRepository.db.QueryContext(ctx, `
+		select g.user_id, g.client_id, c.name, g.scopes, g.createdAt
+		from oauth_grants g inner join oauth_clients c on c.client_id = g.client_id
+		where g.user_id = ?
+		order by g.createdAt`,
+		userID,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var grant models.OAuthGrant
+
+		if grant, err = scanOAuthGrant(lines); err != nil {
+			return
+		}
+
+		grants = append(grants, grant)
+	}
+
+	return
+}
+
+// Create inserts a new grant on the database
+func (oauthGrantsRepository OAuthGrantsRepository) Create(ctx context.Context, grant models.OAuthGrant) (err error) {
+	statement, err := oauthGrantsRepository.db.PrepareContext(ctx, "insert into oauth_grants (user_id, client_id, scopes) values (?, ?, ?)")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, grant.UserID, grant.ClientID, strings.Join(grant.Scopes, " ")); err != nil {
+		return
+	}
+
+	return
+}
+
+// UpdateScopes replaces the scopes granted by the user to the client
+func (oauthGrantsRepository OAuthGrantsRepository) UpdateScopes(ctx context.Context, grant models.OAuthGrant) (err error) {
+	statement, err := oauthGrantsRepository.db.PrepareContext(ctx, "update oauth_grants set scopes = ? where user_id = ? and client_id = ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, strings.Join(grant.Scopes, " "), grant.UserID, grant.ClientID); err != nil {
+		return
+	}
+
+	return
+}
+
+// Delete deletes the grant of an user to a client
+func (oauthGrantsRepository OAuthGrantsRepository) Delete(ctx context.Context, userID uint64, clientID string) (deleted bool, err error) {
+	statement, err := oauthGrantsRepository.db.PrepareContext(ctx, "delete from oauth_grants where user_id = ? and client_id = ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, userID, clientID)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	deleted = rowsAffected == 1
+
+	return
+}
+
+func scanOAuthGrant(lines *sql.Rows) (grant models.OAuthGrant, err error) {
+	var scopes string
+
+	if err = lines.Scan(
+		&grant.UserID,
+		&grant.ClientID,
+		&grant.Name,
+		&scopes,
+		&grant.CreatedAt,
+	); err != nil {
+		return
+	}
+
+	grant.Scopes = strings.Fields(scopes)
+	return
+}
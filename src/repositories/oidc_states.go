@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+	"time"
+)
+
+// OIDCStatesRepository represents a repository of logins started on OpenID Connect providers
+type OIDCStatesRepository struct {
+	db database.DBTX
+}
+
+// NewOIDCStatesRepository creates a new repository of OpenID Connect states
+func NewOIDCStatesRepository(db database.DBTX) *OIDCStatesRepository {
+	return &OIDCStatesRepository{db}
+}
+
+// Create inserts a new state on the database
+func (oidcStatesRepository OIDCStatesRepository) Create(ctx context.Context, state models.OIDCState) (err error) {
+	statement, err := oidcStatesRepository.db.PrepareContext(ctx,
+		"insert into oidc_states (state_hash, provider, nonce, code_verifier, expires_at) values (?, ?, ?, ?, ?)",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, state.StateHash, state.Provider, state.Nonce, state.CodeVerifier, state.ExpiresAt); err != nil {
+		return
+	}
+
+	return
+}
+
+// Consume searchs a state and deletes it, so it can only be used once. Found is false when
+// there was no such state or when someone else consumed it first
+func (oidcStatesRepository OIDCStatesRepository) Consume(ctx context.Context, stateHash string) (state models.OIDCState, found bool, err error) {
+	lines, err := oidcStatesRepository.db.QueryContext(ctx,
+		"select state_hash, provider, nonce, code_verifier, expires_at from oidc_states where state_hash = ?",
+		stateHash,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	if !lines.Next() {
+		return
+	}
+
+	if err = lines.Scan(&state.StateHash, &state.Provider, &state.Nonce, &state.CodeVerifier, &state.ExpiresAt); err != nil {
+		return
+	}
+
+	statement, err := oidcStatesRepository.db.PrepareContext(ctx, "delete from oidc_states where state_hash = ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, stateHash)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	found = rowsAffected == 1
+
+	return
+}
+
+// DeleteExpired deletes the states of logins that can't be finished anymore
+func (oidcStatesRepository OIDCStatesRepository) DeleteExpired(ctx context.Context) (err error) {
+	statement, err := oidcStatesRepository.db.PrepareContext(ctx, "delete from oidc_states where expires_at < ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, time.Now()); err != nil {
+		return
+	}
+
+	return
+}
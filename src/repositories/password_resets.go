@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+	"time"
+)
+
+// PasswordResetsRepository represents a repository of password reset tokens
+type PasswordResetsRepository struct {
+	db database.DBTX
+}
+
+// NewPasswordResetsRepository creates a new repository of password reset tokens
+func NewPasswordResetsRepository(db database.DBTX) *PasswordResetsRepository {
+	return &PasswordResetsRepository{db}
+}
+
+// Create inserts a new password reset token on the database
+func (passwordResetsRepository PasswordResetsRepository) Create(ctx context.Context, passwordResetToken models.PasswordResetToken) (err error) {
+	statement, err := passwordResetsRepository.db.PrepareContext(ctx,
+		"insert into password_resets (user_id, token_hash, expires_at) values (?, ?, ?)",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx,
+		passwordResetToken.UserID,
+		passwordResetToken.TokenHash,
+		passwordResetToken.ExpiresAt,
+	); err != nil {
+		return
+	}
+
+	return
+}
+
+// SearchByHash search a password reset token by its hash
+func (passwordResetsRepository PasswordResetsRepository) SearchByHash(ctx context.Context, tokenHash string) (passwordResetToken models.PasswordResetToken, err error) {
+	line, err := passwordResetsRepository.db.QueryContext(ctx,
+		"select id, user_id, token_hash, expires_at, used_at, createdAt from password_resets where token_hash = ?",
+		tokenHash,
+	)
+	if err != nil {
+		return
+	}
+	defer line.Close()
+
+	if line.Next() {
+		if err = line.Scan(
+			&passwordResetToken.ID,
+			&passwordResetToken.UserID,
+			&passwordResetToken.TokenHash,
+			&passwordResetToken.ExpiresAt,
+			&passwordResetToken.UsedAt,
+			&passwordResetToken.CreatedAt,
+		); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Use marks a password reset token as used, reporting false if it was already used
+func (passwordResetsRepository PasswordResetsRepository) Use(ctx context.Context, passwordResetTokenID uint64) (used bool, err error) {
+	statement, err := passwordResetsRepository.db.PrepareContext(ctx,
+		"update password_resets set used_at = ? where id = ? and used_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, time.Now(), passwordResetTokenID)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	used = rowsAffected == 1
+
+	return
+}
+
+// UseAllFromUser invalidates every pending password reset token of the user
+func (passwordResetsRepository PasswordResetsRepository) UseAllFromUser(ctx context.Context, userID uint64) (err error) {
+	statement, err := passwordResetsRepository.db.PrepareContext(ctx,
+		"update password_resets set used_at = ? where user_id = ? and used_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, time.Now(), userID); err != nil {
+		return
+	}
+
+	return
+}
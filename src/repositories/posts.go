@@ -1,51 +1,44 @@
 package repositories
 
 import (
+	"api/src/database"
 	"api/src/models"
-	"database/sql"
+	"context"
+	"time"
 )
 
 // PostsRepository represents a repository of posts
 type PostsRepository struct {
-	db *sql.DB
+	db database.DBTX
 }
 
 // NewPostRepository creates a new postRepository
-func NewPostRepository(db *sql.DB) *PostsRepository {
+func NewPostRepository(db database.DBTX) *PostsRepository {
 	return &PostsRepository{db}
 }
 
+// WithTx gives the repository of posts on the transaction
+func (postsRepository PostsRepository) WithTx(tx database.DBTX) PostStore {
+	return NewPostRepository(tx)
+}
+
 // CreatePost inserts a post on the database
-func (postsRepository PostsRepository) CreatePost(post models.Post) (userID uint64, err error) {
-	statement, err := postsRepository.db.Prepare(
+func (postsRepository PostsRepository) CreatePost(ctx context.Context, post models.Post) (postID uint64, err error) {
+	postID, err = database.InsertReturningID(ctx, postsRepository.db,
 		"insert into posts (title, content, author_id) values (?, ?, ?)",
+		post.Title, post.Content, post.AuthorID,
 	)
-	if err != nil {
-		return
-	}
-	defer statement.Close()
-
-	result, err := statement.Exec(post.Title, post.Content, post.AuthorID)
-	if err != nil {
-		return
-	}
-
-	lastInsertID, err := result.LastInsertId()
-	if err != nil {
-		return
-	}
-	userID = uint64(lastInsertID)
 
 	return
 }
 
 // SearchByID search a post by its ID
-func (postsRepository PostsRepository) SearchByID(postID uint64) (post models.Post, err error) {
-	lines, err := postsRepository.db.Query(`
-		select p.*, u.nick 
+func (postsRepository PostsRepository) SearchByID(ctx context.Context, postID uint64) (post models.Post, err error) {
+	lines, err := database.Reader(ctx, postsRepository.db).QueryContext(ctx, `
+		select p.id, p.title, p.content, p.author_id, p.likes, p.createdAt, p.version, u.nick
 		from posts p 
 		inner join users u on u.id = p.author_id
-		where p.id = ?`,
+		where p.id = ? and p.deleted_at is null and u.deleted_at is null`,
 		postID,
 	)
 	if err != nil {
@@ -61,6 +54,7 @@ func (postsRepository PostsRepository) SearchByID(postID uint64) (post models.Po
 			&post.AuthorID,
 			&post.Likes,
 			&post.CreatedAt,
+			&post.Version,
 			&post.AuthorNick,
 		); err != nil {
 			return
@@ -71,13 +65,13 @@ func (postsRepository PostsRepository) SearchByID(postID uint64) (post models.Po
 }
 
 // Search gets all posts from the user and those that he follows
-func (postsRepository PostsRepository) Search(userID uint64) (posts []models.Post, err error) {
-	lines, err := postsRepository.db.Query(`
-		select distinct p.*, u.nick 
+func (postsRepository PostsRepository) Search(ctx context.Context, userID uint64) (posts []models.Post, err error) {
+	lines, err := database.Reader(ctx, postsRepository.db).QueryContext(ctx, `
+		select distinct p.id, p.title, p.content, p.author_id, p.likes, p.createdAt, p.version, u.nick
 		from posts p 
 		inner join users u on u.id = p.author_id 
 		left join followers f on p.author_id = f.user_id 
-		where u.id = ? or f.follower_id = ?
+		where p.deleted_at is null and u.deleted_at is null and (u.id = ? or f.follower_id = ?)
 		order by p.createdAt DESC`,
 		userID, userID,
 	)
@@ -96,6 +90,7 @@ func (postsRepository PostsRepository) Search(userID uint64) (posts []models.Pos
 			&post.AuthorID,
 			&post.Likes,
 			&post.CreatedAt,
+			&post.Version,
 			&post.AuthorNick,
 		); err != nil {
 			return
@@ -107,43 +102,114 @@ func (postsRepository PostsRepository) Search(userID uint64) (posts []models.Pos
 	return
 }
 
-// UpdatePost update post's informations
-func (postsRepository PostsRepository) UpdatePost(postID uint64, post models.Post) (err error) {
-	statement, err := postsRepository.db.Prepare("update posts set title = ?, content = ? where id = ?")
+// UpdatePost update post's informations when it is still at the version, moving it to the next one. It isn't
+// updated when someone else changed it since that version
+func (postsRepository PostsRepository) UpdatePost(ctx context.Context, postID, version uint64, post models.Post) (updated bool, err error) {
+	statement, err := postsRepository.db.PrepareContext(ctx,
+		"update posts set title = ?, content = ?, version = version + 1 where id = ? and version = ? and deleted_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, post.Title, post.Content, postID, version)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	updated = rowsAffected == 1
+
+	return
+}
+
+// DeletePost marks a post as deleted, hiding it until it is restored or purged
+func (postsRepository PostsRepository) DeletePost(ctx context.Context, postID uint64) (err error) {
+	statement, err := postsRepository.db.PrepareContext(ctx, "update posts set deleted_at = ? where id = ? and deleted_at is null")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, time.Now(), postID); err != nil {
+		return
+	}
+
+	return
+}
+
+// SearchDeletedPost search a deleted post by its ID, with when it was deleted
+func (postsRepository PostsRepository) SearchDeletedPost(ctx context.Context, postID uint64) (post models.Post, err error) {
+	lines, err := postsRepository.db.QueryContext(ctx,
+		"select id, author_id, deleted_at from posts where id = ? and deleted_at is not null",
+		postID,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	if lines.Next() {
+		if err = lines.Scan(&post.ID, &post.AuthorID, &post.DeletedAt); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// RestorePost brings back the post when it was deleted after deletedSince, reporting false when there is no such post
+func (postsRepository PostsRepository) RestorePost(ctx context.Context, postID uint64, deletedSince time.Time) (restored bool, err error) {
+	statement, err := postsRepository.db.PrepareContext(ctx,
+		"update posts set deleted_at = null where id = ? and deleted_at >= ?",
+	)
 	if err != nil {
 		return
 	}
 	defer statement.Close()
 
-	if _, err = statement.Exec(post.Title, post.Content, postID); err != nil {
+	result, err := statement.ExecContext(ctx, postID, deletedSince)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
 		return
 	}
+	restored = rowsAffected == 1
 
 	return
 }
 
-// DeletePost deletes a post from the Database
-func (postsRepository PostsRepository) DeletePost(postID uint64) (err error) {
-	statement, err := postsRepository.db.Prepare("delete from posts where id = ?")
+// PurgePosts removes for good the posts deleted before deletedBefore
+func (postsRepository PostsRepository) PurgePosts(ctx context.Context, deletedBefore time.Time) (purged int64, err error) {
+	statement, err := postsRepository.db.PrepareContext(ctx, "delete from posts where deleted_at < ?")
 	if err != nil {
 		return
 	}
 	defer statement.Close()
 
-	if _, err = statement.Exec(postID); err != nil {
+	result, err := statement.ExecContext(ctx, deletedBefore)
+	if err != nil {
 		return
 	}
 
+	purged, err = result.RowsAffected()
 	return
 }
 
 // SearchPostsByUser get all posts from an user
-func (postsRepository PostsRepository) SearchPostsByUser(userID uint64) (posts []models.Post, err error) {
-	lines, err := postsRepository.db.Query(`
-		select p.*, u.nick 
+func (postsRepository PostsRepository) SearchPostsByUser(ctx context.Context, userID uint64) (posts []models.Post, err error) {
+	lines, err := database.Reader(ctx, postsRepository.db).QueryContext(ctx, `
+		select p.id, p.title, p.content, p.author_id, p.likes, p.createdAt, p.version, u.nick
 		from posts p 
 		inner join users u on u.id = p.author_id
-		where p.author_id = ?`,
+		where p.author_id = ? and p.deleted_at is null and u.deleted_at is null`,
 		userID,
 	)
 	if err != nil {
@@ -161,6 +227,7 @@ func (postsRepository PostsRepository) SearchPostsByUser(userID uint64) (posts [
 			&post.AuthorID,
 			&post.Likes,
 			&post.CreatedAt,
+			&post.Version,
 			&post.AuthorNick,
 		); err != nil {
 			return
@@ -173,36 +240,36 @@ func (postsRepository PostsRepository) SearchPostsByUser(userID uint64) (posts [
 }
 
 // Like adds one like on a post
-func (postsRepository PostsRepository) Like(postID uint64) (err error) {
-	statement, err := postsRepository.db.Prepare(`
-		update posts set likes = 
-		CASE 
-			WHEN likes > 0 THEN likes - 1
-			ELSE 0 
-		END
-		where id = ?
-	`)
+func (postsRepository PostsRepository) Like(ctx context.Context, postID uint64) (err error) {
+	statement, err := postsRepository.db.PrepareContext(ctx, "update posts set likes = likes + 1 where id = ? and deleted_at is null")
 	if err != nil {
 		return
 	}
 	defer statement.Close()
 
-	if _, err = statement.Exec(postID); err != nil {
+	if _, err = statement.ExecContext(ctx, postID); err != nil {
 		return
 	}
 
 	return
 }
 
-// Like removes one like on a post
-func (postsRepository PostsRepository) UnLike(postID uint64) (err error) {
-	statement, err := postsRepository.db.Prepare("update posts set likes = likes - 1 where id = ?")
+// UnLike removes one like from a post, never going below zero
+func (postsRepository PostsRepository) UnLike(ctx context.Context, postID uint64) (err error) {
+	statement, err := postsRepository.db.PrepareContext(ctx, `
+		update posts set likes = 
+		CASE 
+			WHEN likes > 0 THEN likes - 1
+			ELSE 0 
+		END
+		where id = ? and deleted_at is null
+	`)
 	if err != nil {
 		return
 	}
 	defer statement.Close()
 
-	if _, err = statement.Exec(postID); err != nil {
+	if _, err = statement.ExecContext(ctx, postID); err != nil {
 		return
 	}
 
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"api/src/database"
+	"context"
+	"time"
+)
+
+// RecoveryCodesRepository represents a repository of two-factor recovery codes
+type RecoveryCodesRepository struct {
+	db database.DBTX
+}
+
+// NewRecoveryCodesRepository creates a new repository of recovery codes
+func NewRecoveryCodesRepository(db database.DBTX) *RecoveryCodesRepository {
+	return &RecoveryCodesRepository{db}
+}
+
+// Replace deletes the recovery codes of the user and stores the new ones
+func (recoveryCodesRepository RecoveryCodesRepository) Replace(ctx context.Context, userID uint64, codeHashes []string) (err error) {
+	if err = recoveryCodesRepository.DeleteAllFromUser(ctx, userID); err != nil {
+		return
+	}
+
+	statement, err := recoveryCodesRepository.db.PrepareContext(ctx,
+		"insert into recovery_codes (user_id, code_hash) values (?, ?)",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	for _, codeHash := range codeHashes {
+		if _, err = statement.ExecContext(ctx, userID, codeHash); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Use marks the recovery code of the user with the hash as used, reporting false if it doesn't exist or was
+// already used
+func (recoveryCodesRepository RecoveryCodesRepository) Use(ctx context.Context, userID uint64, codeHash string) (used bool, err error) {
+	statement, err := recoveryCodesRepository.db.PrepareContext(ctx,
+		"update recovery_codes set used_at = ? where user_id = ? and code_hash = ? and used_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, time.Now(), userID, codeHash)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	used = rowsAffected == 1
+
+	return
+}
+
+// DeleteAllFromUser deletes every recovery code of the user
+func (recoveryCodesRepository RecoveryCodesRepository) DeleteAllFromUser(ctx context.Context, userID uint64) (err error) {
+	statement, err := recoveryCodesRepository.db.PrepareContext(ctx, "delete from recovery_codes where user_id = ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, userID); err != nil {
+		return
+	}
+
+	return
+}
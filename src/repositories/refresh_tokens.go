@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+	"time"
+)
+
+// RefreshTokensRepository represents a repository of refresh tokens
+type RefreshTokensRepository struct {
+	db database.DBTX
+}
+
+// NewRefreshTokensRepository creates a new repository of refresh tokens
+func NewRefreshTokensRepository(db database.DBTX) *RefreshTokensRepository {
+	return &RefreshTokensRepository{db}
+}
+
+// Create inserts a new refresh token on the database
+func (refreshTokensRepository RefreshTokensRepository) Create(ctx context.Context, refreshToken models.RefreshToken) (refreshTokenID uint64, err error) {
+	refreshTokenID, err = database.InsertReturningID(ctx, refreshTokensRepository.db,
+		"insert into refresh_tokens (user_id, family_id, token_hash, expires_at) values (?, ?, ?, ?)",
+		refreshToken.UserID,
+		refreshToken.FamilyID,
+		refreshToken.TokenHash,
+		refreshToken.ExpiresAt,
+	)
+
+	return
+}
+
+// SearchByHash search a refresh token by its hash
+func (refreshTokensRepository RefreshTokensRepository) SearchByHash(ctx context.Context, tokenHash string) (refreshToken models.RefreshToken, err error) {
+	lines, err := refreshTokensRepository.db.QueryContext(ctx, `
+		select id, user_id, family_id, token_hash, expires_at, rotated_at, revoked_at, createdAt
+		from refresh_tokens
+		where token_hash = ?`,
+		tokenHash,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	if lines.Next() {
+		if err = lines.Scan(
+			&refreshToken.ID,
+			&refreshToken.UserID,
+			&refreshToken.FamilyID,
+			&refreshToken.TokenHash,
+			&refreshToken.ExpiresAt,
+			&refreshToken.RotatedAt,
+			&refreshToken.RevokedAt,
+			&refreshToken.CreatedAt,
+		); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Rotate marks a refresh token as used, reporting false if it was already used by someone else
+func (refreshTokensRepository RefreshTokensRepository) Rotate(ctx context.Context, refreshTokenID uint64) (rotated bool, err error) {
+	statement, err := refreshTokensRepository.db.PrepareContext(ctx,
+		"update refresh_tokens set rotated_at = ? where id = ? and rotated_at is null and revoked_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, time.Now(), refreshTokenID)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	rotated = rowsAffected == 1
+
+	return
+}
+
+// RevokeFamily revokes every refresh token that belongs to the same family
+func (refreshTokensRepository RefreshTokensRepository) RevokeFamily(ctx context.Context, familyID string) (err error) {
+	statement, err := refreshTokensRepository.db.PrepareContext(ctx,
+		"update refresh_tokens set revoked_at = ? where family_id = ? and revoked_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, time.Now(), familyID); err != nil {
+		return
+	}
+
+	return
+}
+
+// RevokeAllFromUser revokes every refresh token of an user
+func (refreshTokensRepository RefreshTokensRepository) RevokeAllFromUser(ctx context.Context, userID uint64) (err error) {
+	statement, err := refreshTokensRepository.db.PrepareContext(ctx,
+		"update refresh_tokens set revoked_at = ? where user_id = ? and revoked_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, time.Now(), userID); err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"api/src/database"
+	"context"
+	"time"
+)
+
+// RevokedTokensRepository represents a repository of revoked access tokens
+type RevokedTokensRepository struct {
+	db database.DBTX
+}
+
+// NewRevokedTokensRepository creates a new repository of revoked tokens
+func NewRevokedTokensRepository(db database.DBTX) *RevokedTokensRepository {
+	return &RevokedTokensRepository{db}
+}
+
+// Create revokes an access token given its identifier (jti)
+func (revokedTokensRepository RevokedTokensRepository) Create(ctx context.Context, tokenID string, userID uint64, expiresAt time.Time) (err error) {
+	statement, err := revokedTokensRepository.db.PrepareContext(ctx,
+		database.InsertIgnore("revoked_tokens (token_id, user_id, expires_at) values (?, ?, ?)"),
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, tokenID, userID, expiresAt); err != nil {
+		return
+	}
+
+	return
+}
+
+// IsRevoked reports if an access token was revoked
+func (revokedTokensRepository RevokedTokensRepository) IsRevoked(ctx context.Context, tokenID string) (revoked bool, err error) {
+	line, err := revokedTokensRepository.db.QueryContext(ctx, "select 1 from revoked_tokens where token_id = ?", tokenID)
+	if err != nil {
+		return
+	}
+	defer line.Close()
+
+	revoked = line.Next()
+	return
+}
+
+// DeleteExpired removes the revoked tokens that would already be rejected by their expiration
+func (revokedTokensRepository RevokedTokensRepository) DeleteExpired(ctx context.Context) (err error) {
+	statement, err := revokedTokensRepository.db.PrepareContext(ctx, "delete from revoked_tokens where expires_at < ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, time.Now()); err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+	"database/sql"
+)
+
+// RolesRepository represents a repository of roles and of the roles granted to users
+type RolesRepository struct {
+	db database.DBTX
+}
+
+// NewRolesRepository creates a new repository of roles
+func NewRolesRepository(db database.DBTX) *RolesRepository {
+	return &RolesRepository{db}
+}
+
+// SearchAll gets all the roles with their permissions
+func (rolesRepository RolesRepository) SearchAll(ctx context.Context) (roles []models.Role, err error) {
+	lines, err := rolesRepository.db.QueryContext(ctx, `
+		select r.name, rp.permission
+		from roles r left join role_permissions rp on rp.role = r.name
+		order by r.name, rp.permission`,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var name string
+		var permission sql.NullString
+
+		if err = lines.Scan(&name, &permission); err != nil {
+			return
+		}
+
+		if len(roles) == 0 || roles[len(roles)-1].Name != name {
+			roles = append(roles, models.Role{Name: name, Permissions: []string{}})
+		}
+
+		if permission.Valid {
+			roles[len(roles)-1].Permissions = append(roles[len(roles)-1].Permissions, permission.String)
+		}
+	}
+
+	return
+}
+
+// Exists reports if there is a role with the name
+func (rolesRepository RolesRepository) Exists(ctx context.Context, name string) (exists bool, err error) {
+	lines, err := rolesRepository.db.QueryContext(ctx, "select name from roles where name = ?", name)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	exists = lines.Next()
+	return
+}
+
+// SearchUserRoles gets the roles of an user, including the base role every user has, and their permissions
+func (rolesRepository RolesRepository) SearchUserRoles(ctx context.Context, userID uint64, baseRole string) (roles, permissions []string, err error) {
+	lines, err := rolesRepository.db.QueryContext(ctx, `
+		select r.name, rp.permission
+		from roles r left join role_permissions rp on rp.role = r.name
+		where r.name = ? or r.name in (select role from user_roles where user_id = ?)
+		order by r.name, rp.permission`,
+		baseRole, userID,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	grantedPermissions := map[string]bool{}
+	for lines.Next() {
+		var name string
+		var permission sql.NullString
+
+		if err = lines.Scan(&name, &permission); err != nil {
+			return
+		}
+
+		if len(roles) == 0 || roles[len(roles)-1] != name {
+			roles = append(roles, name)
+		}
+
+		if permission.Valid && !grantedPermissions[permission.String] {
+			grantedPermissions[permission.String] = true
+			permissions = append(permissions, permission.String)
+		}
+	}
+
+	return
+}
+
+// Grant grants a role to an user, doing nothing if the user already has it
+func (rolesRepository RolesRepository) Grant(ctx context.Context, userID uint64, role string) (err error) {
+	lines, err := rolesRepository.db.QueryContext(ctx, "select user_id from user_roles where user_id = ? and role = ?", userID, role)
+	if err != nil {
+		return
+	}
+	granted := lines.Next()
+	lines.Close()
+
+	if granted {
+		return
+	}
+
+	statement, err := rolesRepository.db.PrepareContext(ctx, "insert into user_roles (user_id, role) values (?, ?)")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, userID, role); err != nil {
+		return
+	}
+
+	return
+}
+
+// Revoke revokes a role of an user, reporting false if the user didn't have it
+func (rolesRepository RolesRepository) Revoke(ctx context.Context, userID uint64, role string) (revoked bool, err error) {
+	statement, err := rolesRepository.db.PrepareContext(ctx, "delete from user_roles where user_id = ? and role = ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, userID, role)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	revoked = rowsAffected == 1
+
+	return
+}
+
+// CountUsers counts the users granted a role, leaving out the deleted ones
+func (rolesRepository RolesRepository) CountUsers(ctx context.Context, role string) (count int, err error) {
+	lines, err := rolesRepository.db.QueryContext(ctx, `
+		select count(*)
+		from user_roles ur inner join users u on u.id = ur.user_id
+		where ur.role = ? and u.deleted_at is null`,
+		role,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	if lines.Next() {
+		if err = lines.Scan(&count); err != nil {
+			return
+		}
+	}
+
+	return
+}
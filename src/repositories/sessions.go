@@ -0,0 +1,199 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// SessionsRepository represents a repository of sessions
+type SessionsRepository struct {
+	db database.DBTX
+}
+
+// NewSessionsRepository creates a new repository of sessions
+func NewSessionsRepository(db database.DBTX) *SessionsRepository {
+	return &SessionsRepository{db}
+}
+
+// Create inserts a new session on the database
+func (sessionsRepository SessionsRepository) Create(ctx context.Context, session models.Session) (err error) {
+	statement, err := sessionsRepository.db.PrepareContext(ctx,
+		"insert into sessions (id, user_id, client_id, scopes, user_agent, ip, createdAt, last_seen_at) values (?, ?, ?, ?, ?, ?, ?, ?)",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	now := time.Now()
+	if _, err = statement.ExecContext(ctx,
+		session.ID,
+		session.UserID,
+		session.ClientID,
+		strings.Join(session.Scopes, " "),
+		session.UserAgent,
+		session.IP,
+		now,
+		now,
+	); err != nil {
+		return
+	}
+
+	return
+}
+
+// SearchByID search a session by its ID
+func (sessionsRepository SessionsRepository) SearchByID(ctx context.Context, sessionID string) (session models.Session, err error) {
+	lines, err := sessionsRepository.db.QueryContext(ctx, `
+		select s.id, s.user_id, s.client_id, s.scopes, s.user_agent, s.ip, s.createdAt, s.last_seen_at, s.revoked_at
+		from sessions s inner join users u on u.id = s.user_id
+		where s.id = ? and u.deleted_at is null`,
+		sessionID,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	if lines.Next() {
+		if session, err = scanSession(lines); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// SearchActiveByUser gets the sessions of an user that weren't revoked and were seen after the given time
+func (sessionsRepository SessionsRepository) SearchActiveByUser(ctx context.Context, userID uint64, seenAfter time.Time) (sessions []models.Session, err error) {
+	lines, err := sessionsRepository.db.QueryContext(ctx, `
+		select s.id, s.user_id, s.client_id, s.scopes, s.user_agent, s.ip, s.createdAt, s.last_seen_at, s.revoked_at
+		from sessions s inner join users u on u.id = s.user_id
+		where s.user_id = ? and s.revoked_at is null and s.last_seen_at > ? and u.deleted_at is null
+		order by s.last_seen_at desc`,
+		userID, seenAfter,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var session models.Session
+
+		if session, err = scanSession(lines); err != nil {
+			return
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return
+}
+
+// SearchActiveByClient gets the sessions of an OAuth client that weren't revoked
+func (sessionsRepository SessionsRepository) SearchActiveByClient(ctx context.Context, clientID string) (sessions []models.Session, err error) {
+	lines, err := sessionsRepository.db.QueryContext(ctx, `
+		select s.id, s.user_id, s.client_id, s.scopes, s.user_agent, s.ip, s.createdAt, s.last_seen_at, s.revoked_at
+		from sessions s inner join users u on u.id = s.user_id
+		where s.client_id = ? and s.revoked_at is null and u.deleted_at is null`,
+		clientID,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var session models.Session
+
+		if session, err = scanSession(lines); err != nil {
+			return
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return
+}
+
+// Touch records that the session was used now
+func (sessionsRepository SessionsRepository) Touch(ctx context.Context, sessionID string) (err error) {
+	statement, err := sessionsRepository.db.PrepareContext(ctx, "update sessions set last_seen_at = ? where id = ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, time.Now(), sessionID); err != nil {
+		return
+	}
+
+	return
+}
+
+// Revoke ends a session of the user, reporting false if there was no active session to end
+func (sessionsRepository SessionsRepository) Revoke(ctx context.Context, userID uint64, sessionID string) (revoked bool, err error) {
+	statement, err := sessionsRepository.db.PrepareContext(ctx,
+		"update sessions set revoked_at = ? where id = ? and user_id = ? and revoked_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, time.Now(), sessionID, userID)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	revoked = rowsAffected == 1
+
+	return
+}
+
+// RevokeAllFromUser ends every session of an user
+func (sessionsRepository SessionsRepository) RevokeAllFromUser(ctx context.Context, userID uint64) (err error) {
+	statement, err := sessionsRepository.db.PrepareContext(ctx,
+		"update sessions set revoked_at = ? where user_id = ? and revoked_at is null",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, time.Now(), userID); err != nil {
+		return
+	}
+
+	return
+}
+
+func scanSession(lines *sql.Rows) (session models.Session, err error) {
+	var scopes string
+
+	if err = lines.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.ClientID,
+		&scopes,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.RevokedAt,
+	); err != nil {
+		return
+	}
+
+	session.Scopes = strings.Fields(scopes)
+	return
+}
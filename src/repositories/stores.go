@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+	"time"
+)
+
+// UserStore keeps the users and who follows who. UserRepository keeps them on the database and the
+// repositories/memory package keeps them in memory, for the tests
+type UserStore interface {
+	// WithTx gives the store writing on the transaction, for units of work that change the users along with
+	// other repositories
+	WithTx(tx database.DBTX) UserStore
+
+	Create(ctx context.Context, user models.User) (userID uint64, err error)
+	Search(ctx context.Context, nameOrNick string) (users []models.User, err error)
+	SerachByID(ctx context.Context, ID uint64) (user models.User, err error)
+	SearchByEmail(ctx context.Context, email string) (user models.User, err error)
+	NickExists(ctx context.Context, nick string) (exists bool, err error)
+	EmailExists(ctx context.Context, email string) (exists bool, err error)
+	Update(ctx context.Context, ID, version uint64, user models.User) (updated bool, err error)
+	Delete(ctx context.Context, ID uint64) (err error)
+	Restore(ctx context.Context, ID uint64, deletedSince time.Time) (restored bool, err error)
+	Purge(ctx context.Context, deletedBefore time.Time) (purged int64, err error)
+
+	FollowUser(ctx context.Context, userID, followerID uint64) (err error)
+	UnFollowUser(ctx context.Context, userID, followerID uint64) (err error)
+	SearchFollowers(ctx context.Context, userID uint64) (users []models.User, err error)
+	SearchFollowing(ctx context.Context, userID uint64) (users []models.User, err error)
+
+	SearchPassword(ctx context.Context, userID uint64) (hashedPassword string, err error)
+	UpdatePassword(ctx context.Context, userID uint64, hashedPassword string) (updated bool, err error)
+	SearchTokensValidAfter(ctx context.Context, userID uint64) (exists bool, tokensValidAfter *time.Time, err error)
+	RevokeTokens(ctx context.Context, userID uint64) (tokensValidAfter time.Time, err error)
+
+	SearchTOTP(ctx context.Context, userID uint64) (totp models.TOTP, err error)
+	SaveTOTPSecret(ctx context.Context, userID uint64, secret string) (err error)
+	EnableTOTP(ctx context.Context, userID uint64) (err error)
+	DisableTOTP(ctx context.Context, userID uint64) (err error)
+	UseTOTPStep(ctx context.Context, userID, step uint64) (used bool, err error)
+
+	VerifyEmail(ctx context.Context, userID uint64, email string) (err error)
+}
+
+// PostStore keeps the posts of the users, like UserStore does with the users
+type PostStore interface {
+	WithTx(tx database.DBTX) PostStore
+
+	CreatePost(ctx context.Context, post models.Post) (postID uint64, err error)
+	SearchByID(ctx context.Context, postID uint64) (post models.Post, err error)
+	Search(ctx context.Context, userID uint64) (posts []models.Post, err error)
+	SearchPostsByUser(ctx context.Context, userID uint64) (posts []models.Post, err error)
+	UpdatePost(ctx context.Context, postID, version uint64, post models.Post) (updated bool, err error)
+	DeletePost(ctx context.Context, postID uint64) (err error)
+	SearchDeletedPost(ctx context.Context, postID uint64) (post models.Post, err error)
+	RestorePost(ctx context.Context, postID uint64, deletedSince time.Time) (restored bool, err error)
+	PurgePosts(ctx context.Context, deletedBefore time.Time) (purged int64, err error)
+	Like(ctx context.Context, postID uint64) (err error)
+	UnLike(ctx context.Context, postID uint64) (err error)
+}
+
+var (
+	_ UserStore = (*UserRepository)(nil)
+	_ PostStore = (*PostsRepository)(nil)
+)
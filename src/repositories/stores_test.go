@@ -0,0 +1,597 @@
+package repositories_test
+
+import (
+	"api/src/config"
+	"api/src/database"
+	"api/src/migrate"
+	"api/src/models"
+	"api/src/repositories"
+	"api/src/repositories/memory"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// stores are the users and the posts of one backend
+type stores struct {
+	users repositories.UserStore
+	posts repositories.PostStore
+}
+
+// backends open empty stores of every implementation, so each case checks that they behave the same
+var backends = map[string]func(t *testing.T) stores{
+	"memory": func(t *testing.T) stores {
+		store := memory.New()
+		return stores{store.Users(), store.Posts()}
+	},
+	"sqlite": func(t *testing.T) stores {
+		config.DBDriver = "sqlite"
+		config.DBConnectionString = fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)",
+			filepath.Join(t.TempDir(), "socialmedia.db"))
+
+		db, err := database.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		if err = migrate.Up(context.Background(), db); err != nil {
+			t.Fatal(err)
+		}
+
+		return stores{repositories.NewUserRepository(db), repositories.NewPostRepository(db)}
+	},
+}
+
+var storeCases = []struct {
+	name string
+	run  func(t *testing.T, ctx context.Context, s stores)
+}{
+	{"create and search an user", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+
+		user, err := s.users.SerachByID(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if user.ID != userID || user.Name != "User alice" || user.Nick != "alice" || user.Email != "alice@example.com" {
+			t.Errorf("got %+v", user)
+		}
+
+		if user.Version != 1 || user.EmailVerifiedAt != nil || user.Password != "" {
+			t.Errorf("got version %d, verified at %v and password %q", user.Version, user.EmailVerifiedAt, user.Password)
+		}
+
+		byEmail, err := s.users.SearchByEmail(ctx, "Alice@Example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if byEmail.ID != userID || byEmail.Password != "hash-alice" {
+			t.Errorf("search by email got %+v", byEmail)
+		}
+	}},
+	{"refuse a nick or an email in use", func(t *testing.T, ctx context.Context, s stores) {
+		createUser(t, ctx, s, "alice")
+
+		if _, err := s.users.Create(ctx, models.User{Name: "Other", Nick: "alice", Email: "other@example.com", Password: "hash"}); err == nil {
+			t.Error("created an user with a nick in use")
+		}
+
+		if _, err := s.users.Create(ctx, models.User{Name: "Other", Nick: "other", Email: "alice@example.com", Password: "hash"}); err == nil {
+			t.Error("created an user with an email in use")
+		}
+	}},
+	{"search users by a part of the name or of the nick", func(t *testing.T, ctx context.Context, s stores) {
+		aliceID := createUser(t, ctx, s, "alice")
+		createUser(t, ctx, s, "bob")
+		maliceID := createUser(t, ctx, s, "malice")
+
+		users, err := s.users.Search(ctx, "ALIC")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertUserIDs(t, users, aliceID, maliceID)
+	}},
+	{"update an user only at its version", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+		changes := models.User{Name: "Alice", Nick: "alice2", Email: "alice@example.com"}
+
+		updated, err := s.users.Update(ctx, userID, 2, changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if updated {
+			t.Error("updated an user at a stale version")
+		}
+
+		if updated, err = s.users.Update(ctx, userID, 1, changes); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := s.users.SerachByID(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !updated || user.Nick != "alice2" || user.Version != 2 {
+			t.Errorf("got updated %v and %+v", updated, user)
+		}
+	}},
+	{"revoke the tokens keeping the microseconds", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+		revokedAt, err := s.users.RevokeTokens(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, tokensValidAfter, err := s.users.SearchTokensValidAfter(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tokensValidAfter == nil || !tokensValidAfter.Equal(revokedAt) || revokedAt.Nanosecond()%1000 != 0 {
+			t.Errorf("revoked at %v, got %v", revokedAt, tokensValidAfter)
+		}
+	}},
+	{"hide a deleted user until it is restored", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+		if err := s.users.Delete(ctx, userID); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := s.users.SerachByID(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		byEmail, err := s.users.SearchByEmail(ctx, "alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		exists, _, err := s.users.SearchTokensValidAfter(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if user.ID != 0 || byEmail.ID != 0 || exists {
+			t.Errorf("found the deleted user: %+v, %+v, %v", user, byEmail, exists)
+		}
+
+		nickExists, err := s.users.NickExists(ctx, "ALICE")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		emailExists, err := s.users.EmailExists(ctx, "ALICE@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !nickExists || !emailExists {
+			t.Error("the deleted user released its nick or its email")
+		}
+
+		restored, err := s.users.Restore(ctx, userID, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if restored {
+			t.Error("restored an user deleted before the window")
+		}
+
+		if restored, err = s.users.Restore(ctx, userID, time.Now().Add(-time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		if user, err = s.users.SerachByID(ctx, userID); err != nil {
+			t.Fatal(err)
+		}
+
+		if !restored || user.ID != userID {
+			t.Errorf("got restored %v and %+v", restored, user)
+		}
+	}},
+	{"update the password only of users that weren't deleted", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+
+		updated, err := s.users.UpdatePassword(ctx, userID, "new-hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		password, err := s.users.SearchPassword(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !updated || password != "new-hash" {
+			t.Errorf("got updated %v and password %q", updated, password)
+		}
+
+		if err = s.users.Delete(ctx, userID); err != nil {
+			t.Fatal(err)
+		}
+
+		if updated, err = s.users.UpdatePassword(ctx, userID, "newer-hash"); err != nil {
+			t.Fatal(err)
+		}
+
+		if updated {
+			t.Error("updated the password of a deleted user")
+		}
+	}},
+	{"purge the users deleted before the window", func(t *testing.T, ctx context.Context, s stores) {
+		aliceID := createUser(t, ctx, s, "alice")
+		bobID := createUser(t, ctx, s, "bob")
+		createPost(t, ctx, s, aliceID, "Hello")
+
+		if err := s.users.FollowUser(ctx, bobID, aliceID); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.users.Delete(ctx, aliceID); err != nil {
+			t.Fatal(err)
+		}
+
+		purged, err := s.users.Purge(ctx, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		nickExists, err := s.users.NickExists(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		followers, err := s.users.SearchFollowers(ctx, bobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if purged != 1 || nickExists || len(followers) != 0 {
+			t.Errorf("got %d purged, nick exists %v and followers %+v", purged, nickExists, followers)
+		}
+	}},
+	{"follow and unfollow users", func(t *testing.T, ctx context.Context, s stores) {
+		aliceID := createUser(t, ctx, s, "alice")
+		bobID := createUser(t, ctx, s, "bob")
+		carolID := createUser(t, ctx, s, "carol")
+
+		for _, followerID := range []uint64{bobID, carolID, bobID} {
+			if err := s.users.FollowUser(ctx, aliceID, followerID); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		followers, err := s.users.SearchFollowers(ctx, aliceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertUserIDs(t, followers, bobID, carolID)
+
+		if err = s.users.UnFollowUser(ctx, aliceID, bobID); err != nil {
+			t.Fatal(err)
+		}
+
+		following, err := s.users.SearchFollowing(ctx, carolID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertUserIDs(t, following, aliceID)
+
+		if following, err = s.users.SearchFollowing(ctx, bobID); err != nil {
+			t.Fatal(err)
+		}
+		assertUserIDs(t, following)
+	}},
+	{"use each TOTP step once", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+		if err := s.users.SaveTOTPSecret(ctx, userID, "SECRET"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.users.EnableTOTP(ctx, userID); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, step := range []struct {
+			step uint64
+			used bool
+		}{{10, true}, {10, false}, {9, false}, {11, true}} {
+			used, err := s.users.UseTOTPStep(ctx, userID, step.step)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if used != step.used {
+				t.Errorf("step %d: got used %v, want %v", step.step, used, step.used)
+			}
+		}
+
+		totp, err := s.users.SearchTOTP(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if totp.Secret != "SECRET" || !totp.Enabled || totp.LastStep != 11 {
+			t.Errorf("got %+v", totp)
+		}
+	}},
+	{"verify a new email", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+		createUser(t, ctx, s, "bob")
+
+		if err := s.users.VerifyEmail(ctx, userID, "bob@example.com"); err == nil {
+			t.Error("verified an email in use")
+		}
+
+		if err := s.users.VerifyEmail(ctx, userID, "alice2@example.com"); err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := s.users.SerachByID(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if user.Email != "alice2@example.com" || user.EmailVerifiedAt == nil || user.Version != 2 {
+			t.Errorf("got %+v", user)
+		}
+	}},
+	{"create and search a post", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+		postID := createPost(t, ctx, s, userID, "Hello")
+
+		post, err := s.posts.SearchByID(ctx, postID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if post.ID != postID || post.Title != "Hello" || post.AuthorID != userID || post.AuthorNick != "alice" {
+			t.Errorf("got %+v", post)
+		}
+
+		if post.Likes != 0 || post.Version != 1 || post.DeletedAt != nil {
+			t.Errorf("got %d likes, version %d and deleted at %v", post.Likes, post.Version, post.DeletedAt)
+		}
+	}},
+	{"search the posts of the user and of who it follows", func(t *testing.T, ctx context.Context, s stores) {
+		aliceID := createUser(t, ctx, s, "alice")
+		bobID := createUser(t, ctx, s, "bob")
+		carolID := createUser(t, ctx, s, "carol")
+
+		alicePostID := createPost(t, ctx, s, aliceID, "From alice")
+		bobPostID := createPost(t, ctx, s, bobID, "From bob")
+		createPost(t, ctx, s, carolID, "From carol")
+
+		if err := s.users.FollowUser(ctx, bobID, aliceID); err != nil {
+			t.Fatal(err)
+		}
+
+		feed, err := s.posts.Search(ctx, aliceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertPostIDs(t, feed, alicePostID, bobPostID)
+
+		byUser, err := s.posts.SearchPostsByUser(ctx, bobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertPostIDs(t, byUser, bobPostID)
+	}},
+	{"update a post only at its version", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+		postID := createPost(t, ctx, s, userID, "Hello")
+		changes := models.Post{Title: "Edited", Content: "Edited content"}
+
+		updated, err := s.posts.UpdatePost(ctx, postID, 2, changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if updated {
+			t.Error("updated a post at a stale version")
+		}
+
+		if updated, err = s.posts.UpdatePost(ctx, postID, 1, changes); err != nil {
+			t.Fatal(err)
+		}
+
+		post, err := s.posts.SearchByID(ctx, postID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !updated || post.Title != "Edited" || post.Version != 2 {
+			t.Errorf("got updated %v and %+v", updated, post)
+		}
+	}},
+	{"never unlike below zero", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+		postID := createPost(t, ctx, s, userID, "Hello")
+
+		for _, change := range []func(context.Context, uint64) error{s.posts.Like, s.posts.Like, s.posts.UnLike, s.posts.UnLike, s.posts.UnLike, s.posts.Like} {
+			if err := change(ctx, postID); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		post, err := s.posts.SearchByID(ctx, postID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if post.Likes != 1 {
+			t.Errorf("got %d likes, want 1", post.Likes)
+		}
+	}},
+	{"hide a deleted post until it is restored", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+		postID := createPost(t, ctx, s, userID, "Hello")
+
+		if err := s.posts.DeletePost(ctx, postID); err != nil {
+			t.Fatal(err)
+		}
+
+		post, err := s.posts.SearchByID(ctx, postID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		deleted, err := s.posts.SearchDeletedPost(ctx, postID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if post.ID != 0 || deleted.ID != postID || deleted.AuthorID != userID || deleted.DeletedAt == nil {
+			t.Errorf("got %+v and deleted %+v", post, deleted)
+		}
+
+		restored, err := s.posts.RestorePost(ctx, postID, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if post, err = s.posts.SearchByID(ctx, postID); err != nil {
+			t.Fatal(err)
+		}
+
+		if !restored || post.ID != postID {
+			t.Errorf("got restored %v and %+v", restored, post)
+		}
+	}},
+	{"hide the posts of a deleted user", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+		postID := createPost(t, ctx, s, userID, "Hello")
+
+		if err := s.users.Delete(ctx, userID); err != nil {
+			t.Fatal(err)
+		}
+
+		post, err := s.posts.SearchByID(ctx, postID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		posts, err := s.posts.SearchPostsByUser(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if post.ID != 0 || len(posts) != 0 {
+			t.Errorf("found the posts of the deleted user: %+v, %+v", post, posts)
+		}
+	}},
+	{"purge the posts deleted before the window", func(t *testing.T, ctx context.Context, s stores) {
+		userID := createUser(t, ctx, s, "alice")
+		deletedID := createPost(t, ctx, s, userID, "Deleted")
+		keptID := createPost(t, ctx, s, userID, "Kept")
+
+		if err := s.posts.DeletePost(ctx, deletedID); err != nil {
+			t.Fatal(err)
+		}
+
+		purged, err := s.posts.PurgePosts(ctx, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		deleted, err := s.posts.SearchDeletedPost(ctx, deletedID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		kept, err := s.posts.SearchByID(ctx, keptID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if purged != 1 || deleted.ID != 0 || kept.ID != keptID {
+			t.Errorf("got %d purged, deleted %+v and kept %+v", purged, deleted, kept)
+		}
+	}},
+}
+
+func TestStores(t *testing.T) {
+	for backendName, open := range backends {
+		for _, storeCase := range storeCases {
+			t.Run(backendName+"/"+storeCase.name, func(t *testing.T) {
+				storeCase.run(t, context.Background(), open(t))
+			})
+		}
+	}
+}
+
+func createUser(t *testing.T, ctx context.Context, s stores, nick string) uint64 {
+	t.Helper()
+
+	userID, err := s.users.Create(ctx, models.User{
+		Name:     "User " + nick,
+		Nick:     nick,
+		Email:    nick + "@example.com",
+		Password: "hash-" + nick,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return userID
+}
+
+func createPost(t *testing.T, ctx context.Context, s stores, authorID uint64, title string) uint64 {
+	t.Helper()
+
+	postID, err := s.posts.CreatePost(ctx, models.Post{Title: title, Content: title + "!", AuthorID: authorID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return postID
+}
+
+func assertUserIDs(t *testing.T, users []models.User, IDs ...uint64) {
+	t.Helper()
+
+	found := map[uint64]bool{}
+	for _, user := range users {
+		found[user.ID] = true
+	}
+
+	if len(users) != len(IDs) {
+		t.Errorf("got %d users, want %d", len(users), len(IDs))
+	}
+
+	for _, ID := range IDs {
+		if !found[ID] {
+			t.Errorf("user %d is missing", ID)
+		}
+	}
+}
+
+func assertPostIDs(t *testing.T, posts []models.Post, IDs ...uint64) {
+	t.Helper()
+
+	found := map[uint64]bool{}
+	for _, post := range posts {
+		found[post.ID] = true
+	}
+
+	if len(posts) != len(IDs) {
+		t.Errorf("got %d posts, want %d", len(posts), len(IDs))
+	}
+
+	for _, ID := range IDs {
+		if !found[ID] {
+			t.Errorf("post %d is missing", ID)
+		}
+	}
+}
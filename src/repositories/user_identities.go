@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"context"
+)
+
+// UserIdentitiesRepository represents a repository of the accounts users have on OpenID Connect providers
+type UserIdentitiesRepository struct {
+	db database.DBTX
+}
+
+// NewUserIdentitiesRepository creates a new repository of user identities
+func NewUserIdentitiesRepository(db database.DBTX) *UserIdentitiesRepository {
+	return &UserIdentitiesRepository{db}
+}
+
+// Create links an user to its account on a provider
+func (userIdentitiesRepository UserIdentitiesRepository) Create(ctx context.Context, identity models.UserIdentity) (err error) {
+	statement, err := userIdentitiesRepository.db.PrepareContext(ctx,
+		"insert into user_identities (user_id, provider, subject) values (?, ?, ?)",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, identity.UserID, identity.Provider, identity.Subject); err != nil {
+		return
+	}
+
+	return
+}
+
+// SearchUserID search the user linked to an account of a provider, returning 0 when there is none
+func (userIdentitiesRepository UserIdentitiesRepository) SearchUserID(ctx context.Context, provider, subject string) (userID uint64, err error) {
+	lines, err := userIdentitiesRepository.db.QueryContext(ctx, `
+		select i.user_id
+		from user_identities i inner join users u on u.id = i.user_id
+		where i.provider = ? and i.subject = ? and u.deleted_at is null`,
+		provider, subject,
+	)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	if lines.Next() {
+		if err = lines.Scan(&userID); err != nil {
+			return
+		}
+	}
+
+	return
+}
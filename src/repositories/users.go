@@ -1,49 +1,45 @@
 package repositories
 
 import (
+	"api/src/database"
 	"api/src/models"
-	"database/sql"
+	"context"
 	"fmt"
+	"strings"
+	"time"
 )
 
 // users represents a user repositorie
 type UserRepository struct {
-	db *sql.DB
+	db database.DBTX
 }
 
 // NewUserRepository creates a new repositorie of users
-func NewUserRepository(db *sql.DB) *UserRepository {
+func NewUserRepository(db database.DBTX) *UserRepository {
 	return &UserRepository{db}
 }
 
-// Create insert a new user on database
-func (userRepository UserRepository) Create(user models.User) (userID uint64, err error) {
-	statement, err := userRepository.db.Prepare("insert into users (name, nick, email, password) values (?,?,?,?)")
-	if err != nil {
-		return
-	}
-	defer statement.Close()
-
-	result, err := statement.Exec(user.Name, user.Nick, user.Email, user.Password)
-	if err != nil {
-		return
-	}
+// WithTx gives the repository of users on the transaction
+func (userRepository UserRepository) WithTx(tx database.DBTX) UserStore {
+	return NewUserRepository(tx)
+}
 
-	lastInsertID, err := result.LastInsertId()
-	if err != nil {
-		return
-	}
-	userID = uint64(lastInsertID)
+// Create insert a new user on database
+func (userRepository UserRepository) Create(ctx context.Context, user models.User) (userID uint64, err error) {
+	userID, err = database.InsertReturningID(ctx, userRepository.db,
+		"insert into users (name, nick, email, password) values (?,?,?,?)",
+		user.Name, user.Nick, user.Email, user.Password,
+	)
 
 	return
 }
 
 // Serach for a user given by name or nick
-func (userRepository UserRepository) Search(nameOrNick string) (users []models.User, err error) {
-	nameOrNick = fmt.Sprintf("%%%s%%", nameOrNick) // %nameOrNick%
+func (userRepository UserRepository) Search(ctx context.Context, nameOrNick string) (users []models.User, err error) {
+	nameOrNick = fmt.Sprintf("%%%s%%", strings.ToLower(nameOrNick)) // %nameOrNick%
 
-	lines, err := userRepository.db.Query(
-		"select id, name, nick, email, createdAt from users where name LIKE ? or nick LIKE ?",
+	lines, err := database.Reader(ctx, userRepository.db).QueryContext(ctx,
+		"select id, name, nick, email, createdAt from users where deleted_at is null and (lower(name) LIKE ? or lower(nick) LIKE ?)",
 		nameOrNick,
 		nameOrNick,
 	)
@@ -72,9 +68,9 @@ func (userRepository UserRepository) Search(nameOrNick string) (users []models.U
 }
 
 // SearchByID search a user by its ID
-func (userRepository UserRepository) SerachByID(ID uint64) (user models.User, err error) {
-	lines, err := userRepository.db.Query(
-		"select id, name, nick, email, createdAt from users where id = ?",
+func (userRepository UserRepository) SerachByID(ctx context.Context, ID uint64) (user models.User, err error) {
+	lines, err := database.Reader(ctx, userRepository.db).QueryContext(ctx,
+		"select id, name, nick, email, email_verified_at, createdAt, version from users where id = ? and deleted_at is null",
 		ID,
 	)
 	if err != nil {
@@ -88,7 +84,9 @@ func (userRepository UserRepository) SerachByID(ID uint64) (user models.User, er
 			&user.Name,
 			&user.Nick,
 			&user.Email,
+			&user.EmailVerifiedAt,
 			&user.CreatedAt,
+			&user.Version,
 		); err != nil {
 			return
 		}
@@ -98,15 +96,15 @@ func (userRepository UserRepository) SerachByID(ID uint64) (user models.User, er
 }
 
 // SerachByEmail searchs a user by its Email
-func (userRepository UserRepository) SearchByEmail(email string) (user models.User, err error) {
-	line, err := userRepository.db.Query("select id, password from users where email = ?", email)
+func (userRepository UserRepository) SearchByEmail(ctx context.Context, email string) (user models.User, err error) {
+	line, err := userRepository.db.QueryContext(ctx, "select id, email, password, email_verified_at from users where lower(email) = lower(?) and deleted_at is null", email)
 	if err != nil {
 		return
 	}
 	defer line.Close()
 
 	if line.Next() {
-		if err = line.Scan(&user.ID, &user.Password); err != nil {
+		if err = line.Scan(&user.ID, &user.Email, &user.Password, &user.EmailVerifiedAt); err != nil {
 			return
 		}
 	}
@@ -114,49 +112,124 @@ func (userRepository UserRepository) SearchByEmail(email string) (user models.Us
 	return
 }
 
-// Update user information on database
-func (userRepository UserRepository) Update(ID uint64, user models.User) (err error) {
-	statement, err := userRepository.db.Prepare(
-		"update users set name = ?, nick = ?, email = ? where id = ?",
+// NickExists reports if the nick is already used by an user. Deleted users keep their nick until they are purged,
+// so they can still be restored
+func (userRepository UserRepository) NickExists(ctx context.Context, nick string) (exists bool, err error) {
+	lines, err := userRepository.db.QueryContext(ctx, "select id from users where lower(nick) = lower(?)", nick)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	exists = lines.Next()
+	return
+}
+
+// EmailExists reports if the email is already used by an user. Like the nick, deleted users keep their email until
+// they are purged
+func (userRepository UserRepository) EmailExists(ctx context.Context, email string) (exists bool, err error) {
+	lines, err := userRepository.db.QueryContext(ctx, "select id from users where lower(email) = lower(?)", email)
+	if err != nil {
+		return
+	}
+	defer lines.Close()
+
+	exists = lines.Next()
+	return
+}
+
+// Update user information on database when it is still at the version, moving it to the next one. It isn't
+// updated when someone else changed it since that version
+func (userRepository UserRepository) Update(ctx context.Context, ID, version uint64, user models.User) (updated bool, err error) {
+	statement, err := userRepository.db.PrepareContext(ctx,
+		"update users set name = ?, nick = ?, email = ?, version = version + 1 where id = ? and version = ? and deleted_at is null",
 	)
 	if err != nil {
 		return
 	}
 	defer statement.Close()
 
-	if _, err = statement.Exec(user.Name, user.Nick, user.Email, ID); err != nil {
+	result, err := statement.ExecContext(ctx, user.Name, user.Nick, user.Email, ID, version)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
 		return
 	}
+	updated = rowsAffected == 1
 
 	return
 }
 
-// Delete from user by ID
-func (userRepository UserRepository) Delete(ID uint64) (err error) {
-	statement, err := userRepository.db.Prepare("delete from users where id = ?")
+// Delete marks the user as deleted, hiding it with its posts and follows until it is restored or purged
+func (userRepository UserRepository) Delete(ctx context.Context, ID uint64) (err error) {
+	statement, err := userRepository.db.PrepareContext(ctx, "update users set deleted_at = ? where id = ? and deleted_at is null")
 	if err != nil {
 		return
 	}
 	defer statement.Close()
 
-	if _, err = statement.Exec(ID); err != nil {
+	if _, err = statement.ExecContext(ctx, time.Now(), ID); err != nil {
 		return
 	}
 
 	return
 }
 
+// Restore brings back the user when it was deleted after deletedSince, reporting false when there is no such user
+func (userRepository UserRepository) Restore(ctx context.Context, ID uint64, deletedSince time.Time) (restored bool, err error) {
+	statement, err := userRepository.db.PrepareContext(ctx,
+		"update users set deleted_at = null where id = ? and deleted_at >= ?",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, ID, deletedSince)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	restored = rowsAffected == 1
+
+	return
+}
+
+// Purge removes for good the users deleted before deletedBefore, with everything that cascades from them
+func (userRepository UserRepository) Purge(ctx context.Context, deletedBefore time.Time) (purged int64, err error) {
+	statement, err := userRepository.db.PrepareContext(ctx, "delete from users where deleted_at < ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, deletedBefore)
+	if err != nil {
+		return
+	}
+
+	purged, err = result.RowsAffected()
+	return
+}
+
 // FollowUser permits an user to follow another
-func (userRepository UserRepository) FollowUser(userID, followerID uint64) (err error) {
-	statement, err := userRepository.db.Prepare(
-		"insert ignore into followers (user_id, follower_id) values (?, ?)",
+func (userRepository UserRepository) FollowUser(ctx context.Context, userID, followerID uint64) (err error) {
+	statement, err := userRepository.db.PrepareContext(ctx,
+		database.InsertIgnore("followers (user_id, follower_id) values (?, ?)"),
 	)
 	if err != nil {
 		return
 	}
 	defer statement.Close()
 
-	if _, err = statement.Exec(userID, followerID); err != nil {
+	if _, err = statement.ExecContext(ctx, userID, followerID); err != nil {
 		return
 	}
 
@@ -164,8 +237,8 @@ func (userRepository UserRepository) FollowUser(userID, followerID uint64) (err
 }
 
 // UnFollowUser permits an user to follow another
-func (userRepository UserRepository) UnFollowUser(userID, followerID uint64) (err error) {
-	statement, err := userRepository.db.Prepare(
+func (userRepository UserRepository) UnFollowUser(ctx context.Context, userID, followerID uint64) (err error) {
+	statement, err := userRepository.db.PrepareContext(ctx,
 		"delete from followers where user_id = ? and follower_id = ?",
 	)
 	if err != nil {
@@ -173,7 +246,7 @@ func (userRepository UserRepository) UnFollowUser(userID, followerID uint64) (er
 	}
 	defer statement.Close()
 
-	if _, err = statement.Exec(userID, followerID); err != nil {
+	if _, err = statement.ExecContext(ctx, userID, followerID); err != nil {
 		return
 	}
 
@@ -181,12 +254,12 @@ func (userRepository UserRepository) UnFollowUser(userID, followerID uint64) (er
 }
 
 // SearchFollowers gets all followers from a user given its ID
-func (userRepository UserRepository) SearchFollowers(userID uint64) (users []models.User, err error) {
-	lines, err := userRepository.db.Query(`
+func (userRepository UserRepository) SearchFollowers(ctx context.Context, userID uint64) (users []models.User, err error) {
+	lines, err := database.Reader(ctx, userRepository.db).QueryContext(ctx, `
 		select u.id, u.name, u.nick, u.email, u.createdAt
 		from users u 
 		inner join followers f on u.id = f.follower_id
-		where f.user_id = ?
+		where f.user_id = ? and u.deleted_at is null
 	`, userID)
 	if err != nil {
 		return
@@ -212,13 +285,13 @@ func (userRepository UserRepository) SearchFollowers(userID uint64) (users []mod
 	return
 }
 
-// SearchFollowers gets all followers from a user given its ID
-func (userRepository UserRepository) SearchFollowing(userID uint64) (users []models.User, err error) {
-	lines, err := userRepository.db.Query(`
+// SearchFollowing gets all users followed by a user given its ID
+func (userRepository UserRepository) SearchFollowing(ctx context.Context, userID uint64) (users []models.User, err error) {
+	lines, err := database.Reader(ctx, userRepository.db).QueryContext(ctx, `
 		select u.id, u.name, u.nick, u.email, u.createdAt
 		from users u 
-		inner join followers f on u.id = f.follower_id
-		where f.follower_id = ?
+		inner join followers f on u.id = f.user_id
+		where f.follower_id = ? and u.deleted_at is null
 	`, userID)
 	if err != nil {
 		return
@@ -245,8 +318,8 @@ func (userRepository UserRepository) SearchFollowing(userID uint64) (users []mod
 }
 
 // SearchPassword gets a Hashed password by user's ID
-func (userRepository UserRepository) SearchPassword(userID uint64) (hashedPassword string, err error) {
-	line, err := userRepository.db.Query("select password from users where id = ?", userID)
+func (userRepository UserRepository) SearchPassword(ctx context.Context, userID uint64) (hashedPassword string, err error) {
+	line, err := userRepository.db.QueryContext(ctx, "select password from users where id = ? and deleted_at is null", userID)
 	if err != nil {
 		return
 	}
@@ -264,15 +337,166 @@ func (userRepository UserRepository) SearchPassword(userID uint64) (hashedPasswo
 	return
 }
 
-// UpdatePassword updates users password
-func (userRepository UserRepository) UpdatePassword(userID uint64, hashedPassword string) (err error) {
-	statement, err := userRepository.db.Prepare("update users set password = ? where id = ?")
+// UpdatePassword updates users password, reporting false when the user doesn't exist or was deleted
+func (userRepository UserRepository) UpdatePassword(ctx context.Context, userID uint64, hashedPassword string) (updated bool, err error) {
+	statement, err := userRepository.db.PrepareContext(ctx, "update users set password = ? where id = ? and deleted_at is null")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, hashedPassword, userID)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	updated = rowsAffected == 1
+
+	return
+}
+
+// SearchTokensValidAfter gets since when the user's tokens are accepted, reporting if the user exists and wasn't deleted
+func (userRepository UserRepository) SearchTokensValidAfter(ctx context.Context, userID uint64) (exists bool, tokensValidAfter *time.Time, err error) {
+	line, err := userRepository.db.QueryContext(ctx, "select tokens_valid_after from users where id = ? and deleted_at is null", userID)
+	if err != nil {
+		return
+	}
+	defer line.Close()
+
+	if line.Next() {
+		if err = line.Scan(&tokensValidAfter); err != nil {
+			return
+		}
+		exists = true
+	}
+
+	return
+}
+
+// RevokeTokens makes every token issued to the user until now invalid
+func (userRepository UserRepository) RevokeTokens(ctx context.Context, userID uint64) (tokensValidAfter time.Time, err error) {
+	statement, err := userRepository.db.PrepareContext(ctx, "update users set tokens_valid_after = ? where id = ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	tokensValidAfter = time.Now().Truncate(time.Microsecond)
+	if _, err = statement.ExecContext(ctx, tokensValidAfter, userID); err != nil {
+		return
+	}
+
+	return
+}
+
+// SearchTOTP gets the two-factor authentication settings of an user
+func (userRepository UserRepository) SearchTOTP(ctx context.Context, userID uint64) (totp models.TOTP, err error) {
+	line, err := userRepository.db.QueryContext(ctx,
+		"select coalesce(totp_secret, ''), totp_enabled, totp_last_step from users where id = ? and deleted_at is null",
+		userID,
+	)
+	if err != nil {
+		return
+	}
+	defer line.Close()
+
+	if line.Next() {
+		if err = line.Scan(&totp.Secret, &totp.Enabled, &totp.LastStep); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// SaveTOTPSecret stores a new TOTP secret, still disabled until the user confirms it
+func (userRepository UserRepository) SaveTOTPSecret(ctx context.Context, userID uint64, secret string) (err error) {
+	statement, err := userRepository.db.PrepareContext(ctx,
+		"update users set totp_secret = ?, totp_enabled = ?, totp_last_step = 0 where id = ?",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, secret, false, userID); err != nil {
+		return
+	}
+
+	return
+}
+
+// EnableTOTP turns the two-factor authentication on for the user
+func (userRepository UserRepository) EnableTOTP(ctx context.Context, userID uint64) (err error) {
+	statement, err := userRepository.db.PrepareContext(ctx, "update users set totp_enabled = ? where id = ?")
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, true, userID); err != nil {
+		return
+	}
+
+	return
+}
+
+// DisableTOTP turns the two-factor authentication off and forgets the secret
+func (userRepository UserRepository) DisableTOTP(ctx context.Context, userID uint64) (err error) {
+	statement, err := userRepository.db.PrepareContext(ctx,
+		"update users set totp_secret = null, totp_enabled = ?, totp_last_step = 0 where id = ?",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, false, userID); err != nil {
+		return
+	}
+
+	return
+}
+
+// UseTOTPStep records the time step of an accepted TOTP code, reporting false if it (or a later one) was already used
+func (userRepository UserRepository) UseTOTPStep(ctx context.Context, userID, step uint64) (used bool, err error) {
+	statement, err := userRepository.db.PrepareContext(ctx,
+		"update users set totp_last_step = ? where id = ? and totp_last_step < ?",
+	)
+	if err != nil {
+		return
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, step, userID, step)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	used = rowsAffected == 1
+
+	return
+}
+
+// VerifyEmail sets the email of the user as verified, replacing the current one when it changed
+func (userRepository UserRepository) VerifyEmail(ctx context.Context, userID uint64, email string) (err error) {
+	statement, err := userRepository.db.PrepareContext(ctx,
+		"update users set email = ?, email_verified_at = ?, version = version + 1 where id = ?",
+	)
 	if err != nil {
 		return
 	}
 	defer statement.Close()
 
-	if _, err = statement.Exec(hashedPassword, userID); err != nil {
+	if _, err = statement.ExecContext(ctx, email, time.Now(), userID); err != nil {
 		return
 	}
 
@@ -1,12 +1,14 @@
 package router
 
 import (
+	"api/src/repositories"
 	"api/src/router/routes"
+	"database/sql"
 
 	"github.com/gorilla/mux"
 )
 
 // Gerar vai retornar um router com as rotas configuradas
-func Gerar() *mux.Router {
-	return routes.ConfigureRoutes(mux.NewRouter())
+func Gerar(db *sql.DB, users repositories.UserStore, posts repositories.PostStore) *mux.Router {
+	return routes.ConfigureRoutes(mux.NewRouter(), db, users, posts)
 }
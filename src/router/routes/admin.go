@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"api/src/authentication"
+	"api/src/controllers"
+	"net/http"
+)
+
+func AdminRoutes(handlers *controllers.Controllers) []Route {
+	return []Route{
+		{
+			URI:                   "/admin/roles",
+			Method:                http.MethodGet,
+			Function:              handlers.FindRoles,
+			RequireAuthentication: true,
+			Permissions:           []string{authentication.PermissionRolesManage},
+		},
+		{
+			URI:                   "/admin/users/{userId}/roles",
+			Method:                http.MethodGet,
+			Function:              handlers.FindUserRoles,
+			RequireAuthentication: true,
+			Permissions:           []string{authentication.PermissionRolesManage},
+		},
+		{
+			URI:                   "/admin/users/{userId}/roles",
+			Method:                http.MethodPost,
+			Function:              handlers.GrantRole,
+			RequireAuthentication: true,
+			Permissions:           []string{authentication.PermissionRolesManage},
+			Writes:                true,
+		},
+		{
+			URI:                   "/admin/users/{userId}/roles/{role}",
+			Method:                http.MethodDelete,
+			Function:              handlers.RevokeRole,
+			RequireAuthentication: true,
+			Permissions:           []string{authentication.PermissionRolesManage},
+			Writes:                true,
+		},
+		{
+			URI:                   "/admin/lockouts",
+			Method:                http.MethodGet,
+			Function:              handlers.FindLockoutEvents,
+			RequireAuthentication: true,
+			Permissions:           []string{authentication.PermissionLockoutsRead},
+		},
+		{
+			URI:                   "/admin/database",
+			Method:                http.MethodGet,
+			Function:              handlers.FindDatabaseStats,
+			RequireAuthentication: true,
+			Permissions:           []string{authentication.PermissionDatabaseRead},
+		},
+		{
+			URI:                   "/admin/users/{userId}/impersonate",
+			Method:                http.MethodPost,
+			Function:              handlers.Impersonate,
+			RequireAuthentication: true,
+			Permissions:           []string{authentication.PermissionUsersImpersonate},
+			Writes:                true,
+		},
+		{
+			URI:                   "/admin/users/{userId}/restore",
+			Method:                http.MethodPost,
+			Function:              handlers.RestoreUser,
+			RequireAuthentication: true,
+			Permissions:           []string{authentication.PermissionUsersDeleteAny},
+			Writes:                true,
+		},
+		{
+			URI:                   "/admin/impersonations",
+			Method:                http.MethodGet,
+			Function:              handlers.FindImpersonations,
+			RequireAuthentication: true,
+			Permissions:           []string{authentication.PermissionImpersonationsRead},
+		},
+		{
+			URI:                   "/admin/impersonations/{impersonationId}/requests",
+			Method:                http.MethodGet,
+			Function:              handlers.FindImpersonatedRequests,
+			RequireAuthentication: true,
+			Permissions:           []string{authentication.PermissionImpersonationsRead},
+		},
+		{
+			URI:                   "/admin/impersonations/{impersonationId}",
+			Method:                http.MethodDelete,
+			Function:              handlers.EndImpersonation,
+			RequireAuthentication: true,
+			Permissions:           []string{authentication.PermissionUsersImpersonate},
+			Writes:                true,
+		},
+	}
+}
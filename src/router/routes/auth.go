@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+)
+
+func AuthRoutes(handlers *controllers.Controllers) []Route {
+	return []Route{
+		{
+			URI:                   "/auth/refresh",
+			Method:                http.MethodPost,
+			Function:              handlers.RefreshToken,
+			RequireAuthentication: false,
+			Writes:                true,
+		},
+		{
+			URI:                   "/logout",
+			Method:                http.MethodPost,
+			Function:              handlers.Logout,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/logout-all",
+			Method:                http.MethodPost,
+			Function:              handlers.LogoutAll,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/.well-known/jwks.json",
+			Method:                http.MethodGet,
+			Function:              handlers.JWKS,
+			RequireAuthentication: false,
+		},
+	}
+}
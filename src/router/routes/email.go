@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+)
+
+func EmailRoutes(handlers *controllers.Controllers) []Route {
+	return []Route{
+		{
+			URI:                   "/email/verify",
+			Method:                http.MethodPost,
+			Function:              handlers.VerifyEmail,
+			RequireAuthentication: false,
+			Writes:                true,
+		},
+		{
+			URI:                   "/email/resend",
+			Method:                http.MethodPost,
+			Function:              handlers.ResendEmailVerification,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+	}
+}
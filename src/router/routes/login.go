@@ -5,9 +5,21 @@ import (
 	"net/http"
 )
 
-var LoginRoutes = Route{
-	URI:                   "/login",
-	Method:                http.MethodPost,
-	Function:              controllers.Login,
-	RequireAuthentication: false,
+func LoginRoutes(handlers *controllers.Controllers) []Route {
+	return []Route{
+		{
+			URI:                   "/login",
+			Method:                http.MethodPost,
+			Function:              handlers.Login,
+			RequireAuthentication: false,
+			Writes:                true,
+		},
+		{
+			URI:                   "/login/mfa",
+			Method:                http.MethodPost,
+			Function:              handlers.LoginMFA,
+			RequireAuthentication: false,
+			Writes:                true,
+		},
+	}
 }
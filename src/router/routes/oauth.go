@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+)
+
+func OAuthRoutes(handlers *controllers.Controllers) []Route {
+	return []Route{
+		{
+			URI:                   "/oauth/clients",
+			Method:                http.MethodPost,
+			Function:              handlers.CreateOAuthClient,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/oauth/clients",
+			Method:                http.MethodGet,
+			Function:              handlers.FindOAuthClients,
+			RequireAuthentication: true,
+		},
+		{
+			URI:                   "/oauth/clients/{clientId}",
+			Method:                http.MethodDelete,
+			Function:              handlers.DeleteOAuthClient,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/oauth/authorize",
+			Method:                http.MethodGet,
+			Function:              handlers.OAuthConsent,
+			RequireAuthentication: true,
+		},
+		{
+			URI:                   "/oauth/authorize",
+			Method:                http.MethodPost,
+			Function:              handlers.Authorize,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/oauth/token",
+			Method:                http.MethodPost,
+			Function:              handlers.OAuthToken,
+			RequireAuthentication: false,
+			Writes:                true,
+		},
+		{
+			URI:                   "/oauth/revoke",
+			Method:                http.MethodPost,
+			Function:              handlers.RevokeOAuthToken,
+			RequireAuthentication: false,
+			Writes:                true,
+		},
+	}
+}
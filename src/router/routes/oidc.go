@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+)
+
+func OIDCRoutes(handlers *controllers.Controllers) []Route {
+	return []Route{
+		{
+			URI:                   "/auth/oidc",
+			Method:                http.MethodGet,
+			Function:              handlers.OIDCProviders,
+			RequireAuthentication: false,
+		},
+		{
+			URI:                   "/auth/oidc/{provider}",
+			Method:                http.MethodGet,
+			Function:              handlers.OIDCLogin,
+			RequireAuthentication: false,
+			Writes:                true,
+		},
+		{
+			URI:                   "/auth/oidc/{provider}/callback",
+			Method:                http.MethodGet,
+			Function:              handlers.OIDCCallback,
+			RequireAuthentication: false,
+			Writes:                true,
+		},
+	}
+}
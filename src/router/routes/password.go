@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+)
+
+func PasswordRoutes(handlers *controllers.Controllers) []Route {
+	return []Route{
+		{
+			URI:                   "/password/forgot",
+			Method:                http.MethodPost,
+			Function:              handlers.ForgotPassword,
+			RequireAuthentication: false,
+			Writes:                true,
+		},
+		{
+			URI:                   "/password/reset",
+			Method:                http.MethodPost,
+			Function:              handlers.ResetPassword,
+			RequireAuthentication: false,
+			Writes:                true,
+		},
+	}
+}
@@ -1,57 +1,81 @@
 package routes
 
 import (
+	"api/src/authentication"
 	"api/src/controllers"
 	"net/http"
 )
 
-var PostsRoutes = []Route{
-	{
-		URI:                   "/posts",
-		Method:                http.MethodPost,
-		Function:              controllers.CreatePost,
-		RequireAuthentication: false,
-	},
-	{
-		URI:                   "/posts",
-		Method:                http.MethodGet,
-		Function:              controllers.FindPosts,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/posts/{postId}",
-		Method:                http.MethodGet,
-		Function:              controllers.FindPost,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/posts/{postId}",
-		Method:                http.MethodPut,
-		Function:              controllers.UpdatePost,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/posts/{postId}",
-		Method:                http.MethodDelete,
-		Function:              controllers.DeletePost,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/users/{userId}/posts",
-		Method:                http.MethodGet,
-		Function:              controllers.SeachPostsByUser,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/posts/{postId}/like",
-		Method:                http.MethodGet,
-		Function:              controllers.LikePost,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/posts/{postId}/unlike",
-		Method:                http.MethodGet,
-		Function:              controllers.UnLikePost,
-		RequireAuthentication: true,
-	},
+func PostsRoutes(handlers *controllers.Controllers) []Route {
+	return []Route{
+		{
+			URI:                   "/posts",
+			Method:                http.MethodPost,
+			Function:              handlers.CreatePost,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopePostsWrite},
+			Writes:                true,
+		},
+		{
+			URI:                   "/posts",
+			Method:                http.MethodGet,
+			Function:              handlers.FindPosts,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopePostsRead},
+		},
+		{
+			URI:                   "/posts/{postId}",
+			Method:                http.MethodGet,
+			Function:              handlers.FindPost,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopePostsRead},
+		},
+		{
+			URI:                   "/posts/{postId}",
+			Method:                http.MethodPut,
+			Function:              handlers.UpdatePost,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopePostsWrite},
+			Writes:                true,
+		},
+		{
+			URI:                   "/posts/{postId}",
+			Method:                http.MethodDelete,
+			Function:              handlers.DeletePost,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopePostsWrite},
+			Writes:                true,
+		},
+		{
+			URI:                   "/posts/{postId}/restore",
+			Method:                http.MethodPost,
+			Function:              handlers.RestorePost,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopePostsWrite},
+			Writes:                true,
+		},
+		{
+			URI:                   "/users/{userId}/posts",
+			Method:                http.MethodGet,
+			Function:              handlers.SeachPostsByUser,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopePostsRead},
+		},
+		{
+			URI:                   "/posts/{postId}/like",
+			Method:                http.MethodGet,
+			Function:              handlers.LikePost,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopePostsWrite},
+			Writes:                true,
+		},
+		{
+			URI:                   "/posts/{postId}/unlike",
+			Method:                http.MethodGet,
+			Function:              handlers.UnLikePost,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopePostsWrite},
+			Writes:                true,
+		},
+	}
 }
@@ -1,7 +1,10 @@
 package routes
 
 import (
+	"api/src/controllers"
 	"api/src/middlewares"
+	"api/src/repositories"
+	"database/sql"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -13,29 +16,43 @@ type Route struct {
 	Method                string
 	Function              func(http.ResponseWriter, *http.Request)
 	RequireAuthentication bool
+	Scopes                []string
+	Permissions           []string
+
+	// Writes marks the routes that change something, whatever their method: their reads go to the primary
+	// database, and read only impersonations can't use them
+	Writes bool
 }
 
-// ConfigureRoutes configure all routes for the API
-func ConfigureRoutes(r *mux.Router) *mux.Router {
-	routes := getAllRoutes()
+// ConfigureRoutes configure all routes for the API, keeping users and posts on their stores and everything else on db
+func ConfigureRoutes(r *mux.Router, db *sql.DB, users repositories.UserStore, posts repositories.PostStore) *mux.Router {
+	routes := getAllRoutes(controllers.New(db, users, posts))
 
 	for _, route := range routes {
 		if route.RequireAuthentication {
 			r.HandleFunc(route.URI,
-				middlewares.Logger(middlewares.Authenticates(route.Function)),
+				middlewares.Logger(middlewares.Deadline(middlewares.Authenticates(db, route.Writes, middlewares.ReadYourWrites(route.Writes,
+					middlewares.RequireScopes(route.Scopes, middlewares.RequirePermissions(route.Permissions, route.Function)),
+				)))),
 			).Methods(route.Method)
 		} else {
-			r.HandleFunc(route.URI, middlewares.Logger(route.Function)).Methods(route.Method)
+			r.HandleFunc(route.URI, middlewares.Logger(middlewares.Deadline(middlewares.ReadYourWrites(route.Writes, route.Function)))).Methods(route.Method)
 		}
 	}
 
 	return r
 }
 
-func getAllRoutes() (routes []Route) {
-	routes = append(routes, LoginRoutes)
-	routes = append(routes, UserRoutes...)
-	routes = append(routes, PostsRoutes...)
+func getAllRoutes(handlers *controllers.Controllers) (routes []Route) {
+	routes = append(routes, LoginRoutes(handlers)...)
+	routes = append(routes, AuthRoutes(handlers)...)
+	routes = append(routes, OIDCRoutes(handlers)...)
+	routes = append(routes, PasswordRoutes(handlers)...)
+	routes = append(routes, EmailRoutes(handlers)...)
+	routes = append(routes, OAuthRoutes(handlers)...)
+	routes = append(routes, UserRoutes(handlers)...)
+	routes = append(routes, PostsRoutes(handlers)...)
+	routes = append(routes, AdminRoutes(handlers)...)
 
 	return
 }
@@ -1,69 +1,152 @@
 package routes
 
 import (
+	"api/src/authentication"
 	"api/src/controllers"
 	"net/http"
 )
 
-var UserRoutes = []Route{
-	{
-		URI:                   "/users",
-		Method:                http.MethodPost,
-		Function:              controllers.CreateUser,
-		RequireAuthentication: false,
-	},
-	{
-		URI:                   "/users",
-		Method:                http.MethodGet,
-		Function:              controllers.FindUsers,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/users/{userId}",
-		Method:                http.MethodGet,
-		Function:              controllers.FindUser,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/users/{userId}",
-		Method:                http.MethodPut,
-		Function:              controllers.UpdateUser,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/users/{userId}",
-		Method:                http.MethodDelete,
-		Function:              controllers.DeleteUser,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/users/{userId}/follow",
-		Method:                http.MethodPost,
-		Function:              controllers.FollowUser,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/users/{userId}/unfollow",
-		Method:                http.MethodPost,
-		Function:              controllers.UnFollowUser,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/users/{userId}/followers",
-		Method:                http.MethodPost,
-		Function:              controllers.SearchFollowers,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/users/{userId}/following",
-		Method:                http.MethodPost,
-		Function:              controllers.SearchFollowing,
-		RequireAuthentication: true,
-	},
-	{
-		URI:                   "/users/{userId}/update-password",
-		Method:                http.MethodPost,
-		Function:              controllers.UpdatePassword,
-		RequireAuthentication: true,
-	},
+func UserRoutes(handlers *controllers.Controllers) []Route {
+	return []Route{
+		{
+			URI:                   "/users",
+			Method:                http.MethodPost,
+			Function:              handlers.CreateUser,
+			RequireAuthentication: false,
+			Writes:                true,
+		},
+		{
+			URI:                   "/users",
+			Method:                http.MethodGet,
+			Function:              handlers.FindUsers,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopeUsersRead},
+		},
+		{
+			URI:                   "/users/{userId}",
+			Method:                http.MethodGet,
+			Function:              handlers.FindUser,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopeUsersRead},
+		},
+		{
+			URI:                   "/users/{userId}",
+			Method:                http.MethodPut,
+			Function:              handlers.UpdateUser,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopeUsersWrite},
+			Writes:                true,
+		},
+		{
+			URI:                   "/users/{userId}",
+			Method:                http.MethodDelete,
+			Function:              handlers.DeleteUser,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/users/{userId}/follow",
+			Method:                http.MethodPost,
+			Function:              handlers.FollowUser,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopeUsersWrite},
+			Writes:                true,
+		},
+		{
+			URI:                   "/users/{userId}/unfollow",
+			Method:                http.MethodPost,
+			Function:              handlers.UnFollowUser,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopeUsersWrite},
+			Writes:                true,
+		},
+		{
+			URI:                   "/users/{userId}/followers",
+			Method:                http.MethodPost,
+			Function:              handlers.SearchFollowers,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopeUsersRead},
+		},
+		{
+			URI:                   "/users/{userId}/following",
+			Method:                http.MethodPost,
+			Function:              handlers.SearchFollowing,
+			RequireAuthentication: true,
+			Scopes:                []string{authentication.ScopeUsersRead},
+		},
+		{
+			URI:                   "/users/{userId}/update-password",
+			Method:                http.MethodPost,
+			Function:              handlers.UpdatePassword,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/users/{userId}/totp",
+			Method:                http.MethodPost,
+			Function:              handlers.EnrollTOTP,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/users/{userId}/totp/confirm",
+			Method:                http.MethodPost,
+			Function:              handlers.ConfirmTOTP,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/users/{userId}/totp/disable",
+			Method:                http.MethodPost,
+			Function:              handlers.DisableTOTP,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/users/{userId}/api-keys",
+			Method:                http.MethodPost,
+			Function:              handlers.CreateAPIKey,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/users/{userId}/api-keys",
+			Method:                http.MethodGet,
+			Function:              handlers.FindAPIKeys,
+			RequireAuthentication: true,
+		},
+		{
+			URI:                   "/users/{userId}/api-keys/{apiKeyId}",
+			Method:                http.MethodDelete,
+			Function:              handlers.DeleteAPIKey,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/users/{userId}/sessions",
+			Method:                http.MethodGet,
+			Function:              handlers.FindSessions,
+			RequireAuthentication: true,
+		},
+		{
+			URI:                   "/users/{userId}/sessions/{sessionId}",
+			Method:                http.MethodDelete,
+			Function:              handlers.DeleteSession,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+		{
+			URI:                   "/users/{userId}/oauth-grants",
+			Method:                http.MethodGet,
+			Function:              handlers.FindOAuthGrants,
+			RequireAuthentication: true,
+		},
+		{
+			URI:                   "/users/{userId}/oauth-grants/{clientId}",
+			Method:                http.MethodDelete,
+			Function:              handlers.DeleteOAuthGrant,
+			RequireAuthentication: true,
+			Writes:                true,
+		},
+	}
 }
@@ -0,0 +1,189 @@
+package security
+
+import (
+	"api/src/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrMismatchedPassword is returned when a password doesn't match its hash
+var ErrMismatchedPassword = errors.New("the password doesn't match")
+
+// Hasher turns passwords into self describing hashes (PHC or modular crypt format) and checks passwords against them
+type Hasher interface {
+	// Hash creates the hash of a password
+	Hash(password string) ([]byte, error)
+
+	// Validate checks a password against a hash made by this hasher
+	Validate(passwordHash, password string) error
+
+	// Recognizes reports if the hash was made by this kind of hasher
+	Recognizes(passwordHash string) bool
+
+	// Outdated reports if the hash was made with other parameters than the hasher's
+	Outdated(passwordHash string) bool
+}
+
+// BcryptHasher hashes passwords with bcrypt
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash creates a bcrypt hash of the password
+func (hasher BcryptHasher) Hash(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), hasher.Cost)
+}
+
+// Validate checks a password against a bcrypt hash
+func (hasher BcryptHasher) Validate(passwordHash, password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password))
+}
+
+// Recognizes reports if the hash is a bcrypt hash ($2a$, $2b$ or $2y$)
+func (hasher BcryptHasher) Recognizes(passwordHash string) bool {
+	return strings.HasPrefix(passwordHash, "$2")
+}
+
+// Outdated reports if the hash was made with another cost
+func (hasher BcryptHasher) Outdated(passwordHash string) bool {
+	cost, err := bcrypt.Cost([]byte(passwordHash))
+	return err != nil || cost != hasher.Cost
+}
+
+// Argon2idHasher hashes passwords with Argon2id, encoding them on the PHC string format
+// ($argon2id$v=19$m=65536,t=3,p=2$salt$hash)
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type argon2idHash struct {
+	Argon2idHasher
+	salt []byte
+	key  []byte
+}
+
+// Hash creates an Argon2id hash of the password with a random salt
+func (hasher Argon2idHasher) Hash(password string) ([]byte, error) {
+	salt := make([]byte, hasher.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, hasher.Iterations, hasher.Memory, hasher.Parallelism, hasher.KeyLength)
+
+	return []byte(fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		hasher.Memory,
+		hasher.Iterations,
+		hasher.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+// Validate checks a password against an Argon2id hash, using the parameters stored on the hash
+func (hasher Argon2idHasher) Validate(passwordHash, password string) error {
+	hash, err := decodeArgon2id(passwordHash)
+	if err != nil {
+		return err
+	}
+
+	key := argon2.IDKey([]byte(password), hash.salt, hash.Iterations, hash.Memory, hash.Parallelism, hash.KeyLength)
+	if subtle.ConstantTimeCompare(key, hash.key) != 1 {
+		return ErrMismatchedPassword
+	}
+
+	return nil
+}
+
+// Recognizes reports if the hash is an Argon2id hash
+func (hasher Argon2idHasher) Recognizes(passwordHash string) bool {
+	return strings.HasPrefix(passwordHash, "$argon2id$")
+}
+
+// Outdated reports if the hash was made with other parameters
+func (hasher Argon2idHasher) Outdated(passwordHash string) bool {
+	hash, err := decodeArgon2id(passwordHash)
+	if err != nil {
+		return true
+	}
+
+	return hash.Memory != hasher.Memory ||
+		hash.Iterations != hasher.Iterations ||
+		hash.Parallelism != hasher.Parallelism ||
+		uint32(len(hash.salt)) != hasher.SaltLength ||
+		hash.KeyLength != hasher.KeyLength
+}
+
+func decodeArgon2id(passwordHash string) (hash argon2idHash, err error) {
+	parts := strings.Split(passwordHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		err = errors.New("invalid Argon2id hash")
+		return
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return
+	}
+
+	if version != argon2.Version {
+		err = fmt.Errorf("unsupported Argon2 version %d", version)
+		return
+	}
+
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hash.Memory, &hash.Iterations, &hash.Parallelism); err != nil {
+		return
+	}
+
+	if hash.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return
+	}
+
+	if hash.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return
+	}
+
+	hash.SaltLength = uint32(len(hash.salt))
+	hash.KeyLength = uint32(len(hash.key))
+	return
+}
+
+// CurrentHasher returns the hasher chosen by the configuration, used for every new hash
+func CurrentHasher() Hasher {
+	if config.PasswordHashAlgorithm == "bcrypt" {
+		return BcryptHasher{Cost: config.BcryptCost}
+	}
+
+	return Argon2idHasher{
+		Memory:      config.Argon2Memory,
+		Iterations:  config.Argon2Iterations,
+		Parallelism: config.Argon2Parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// hasherFor finds the hasher that made a hash, so hashes made before a change of algorithm keep working
+func hasherFor(passwordHash string) (Hasher, error) {
+	hashers := []Hasher{CurrentHasher(), BcryptHasher{Cost: config.BcryptCost}, Argon2idHasher{}}
+
+	for _, hasher := range hashers {
+		if hasher.Recognizes(passwordHash) {
+			return hasher, nil
+		}
+	}
+
+	return nil, errors.New("unknown password hash format")
+}
@@ -0,0 +1,114 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// smallArgon2id keeps the tests fast, the parameters don't change the encoding
+var smallArgon2id = Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idReferenceHash(t *testing.T) {
+	// From the test vectors of the Argon2 reference implementation: "password" salted with "somesalt"
+	const referenceHash = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+
+	if err := (Argon2idHasher{}).Validate(referenceHash, "password"); err != nil {
+		t.Errorf("the reference hash didn't validate: %v", err)
+	}
+
+	if err := (Argon2idHasher{}).Validate(referenceHash, "Password"); !errors.Is(err, ErrMismatchedPassword) {
+		t.Errorf("got %v for a wrong password", err)
+	}
+}
+
+func TestArgon2idEncodeDecode(t *testing.T) {
+	passwordHash, err := smallArgon2id.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(passwordHash), "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("got %s", passwordHash)
+	}
+
+	hash, err := decodeArgon2id(string(passwordHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hash.Argon2idHasher != smallArgon2id {
+		t.Errorf("decoded %+v, want %+v", hash.Argon2idHasher, smallArgon2id)
+	}
+
+	if err = smallArgon2id.Validate(string(passwordHash), "correct horse"); err != nil {
+		t.Errorf("the password didn't validate: %v", err)
+	}
+
+	if smallArgon2id.Outdated(string(passwordHash)) {
+		t.Error("the hash is outdated for its own parameters")
+	}
+
+	stronger := smallArgon2id
+	stronger.Iterations++
+	if !stronger.Outdated(string(passwordHash)) {
+		t.Error("the hash isn't outdated for more iterations")
+	}
+}
+
+func TestDecodeArgon2idInvalid(t *testing.T) {
+	cases := []struct {
+		name         string
+		passwordHash string
+	}{
+		{"bcrypt", "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"},
+		{"argon2i", "$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{"another version", "$argon2id$v=16$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{"without parameters", "$argon2id$v=19$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{"invalid parameters", "$argon2id$v=19$m=a,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{"an invalid salt", "$argon2id$v=19$m=65536,t=2,p=1$c29tZ!XNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{"an invalid key", "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc="},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := decodeArgon2id(testCase.passwordHash); err == nil {
+				t.Error("decoded an invalid hash")
+			}
+
+			if err := smallArgon2id.Validate(testCase.passwordHash, "password"); err == nil {
+				t.Error("validated an invalid hash")
+			}
+
+			if !smallArgon2id.Outdated(testCase.passwordHash) {
+				t.Error("an invalid hash isn't outdated")
+			}
+		})
+	}
+}
+
+func TestHasherFor(t *testing.T) {
+	cases := []struct {
+		passwordHash string
+		want         Hasher
+	}{
+		{"$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", BcryptHasher{}},
+		{"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc", Argon2idHasher{}},
+		{"plain text", nil},
+	}
+
+	for _, testCase := range cases {
+		hasher, err := hasherFor(testCase.passwordHash)
+		if testCase.want == nil {
+			if err == nil {
+				t.Errorf("%s: found a hasher for an unknown format", testCase.passwordHash)
+			}
+			continue
+		}
+
+		if err != nil || fmt.Sprintf("%T", hasher) != fmt.Sprintf("%T", testCase.want) {
+			t.Errorf("%s: got %T, %v", testCase.passwordHash, hasher, err)
+		}
+	}
+}
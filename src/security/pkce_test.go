@@ -0,0 +1,45 @@
+package security
+
+import (
+	"strings"
+	"testing"
+)
+
+// The example of RFC 7636, appendix B
+const (
+	rfc7636Verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	rfc7636Challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestPKCEChallenge(t *testing.T) {
+	if challenge := PKCEChallenge(rfc7636Verifier); challenge != rfc7636Challenge {
+		t.Errorf("got %s, want %s", challenge, rfc7636Challenge)
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	longVerifier := strings.Repeat("a", 128)
+
+	cases := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{"the RFC 7636 example", rfc7636Verifier, rfc7636Challenge, true},
+		{"the longest verifier", longVerifier, PKCEChallenge(longVerifier), true},
+		{"another verifier", strings.Repeat("b", 43), rfc7636Challenge, false},
+		{"the plain method", rfc7636Verifier, rfc7636Verifier, false},
+		{"an empty challenge", rfc7636Verifier, "", false},
+		{"a verifier too short", rfc7636Verifier[:42], PKCEChallenge(rfc7636Verifier[:42]), false},
+		{"a verifier too long", longVerifier + "a", PKCEChallenge(longVerifier + "a"), false},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := VerifyPKCE(testCase.verifier, testCase.challenge); got != testCase.want {
+				t.Errorf("got %v, want %v", got, testCase.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package security
+
+import (
+	"api/src/config"
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords are words so frequent on leaked passwords that they add almost nothing to a password
+var commonPasswords = []string{
+	"password", "passw0rd", "qwerty", "letmein", "welcome", "admin", "login", "iloveyou",
+	"monkey", "dragon", "master", "football", "baseball", "sunshine", "princess", "shadow",
+	"superman", "trustno1", "secret", "abc", "123", "senha", "changeme", "hello", "freedom",
+}
+
+// keyboardRows are used to find keyboard walks like "qwerty" or "asdf"
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// breachedPasswords holds the list loaded from a single file, grouped by the first 5 characters of the SHA-1
+var breachedPasswords map[string]map[string]struct{}
+
+// CheckPasswordStrength applies the password policy: the minimum length, the minimum strength score, not
+// containing the personal information of the user (like name, nick or email) and not being a breached password
+func CheckPasswordStrength(password string, personalInformation ...string) error {
+	if len([]rune(password)) < config.PasswordMinLength {
+		return fmt.Errorf("the password must have at least %d characters", config.PasswordMinLength)
+	}
+
+	lowerPassword := strings.ToLower(password)
+	for _, information := range personalInformation {
+		for _, part := range personalInformationParts(information) {
+			if strings.Contains(lowerPassword, part) {
+				return errors.New("the password can't contain your name, nick or email")
+			}
+		}
+	}
+
+	if PasswordScore(password) < config.PasswordMinScore {
+		return errors.New("the password is too easy to guess, try a longer one mixing words, numbers and symbols")
+	}
+
+	breached, err := IsBreachedPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if breached {
+		return errors.New("the password was exposed on a data breach, please choose another one")
+	}
+
+	return nil
+}
+
+// PasswordScore estimates how hard a password is to guess on a 0 to 4 scale, like zxcvbn does: common words,
+// repetitions, sequences and keyboard walks add little to the estimated entropy
+func PasswordScore(password string) int {
+	bits := passwordEntropy(password)
+
+	// The thresholds are the zxcvbn ones (10^3, 10^6, 10^8 and 10^10 guesses) expressed in bits
+	switch {
+	case bits < 10:
+		return 0
+	case bits < 20:
+		return 1
+	case bits < 26.6:
+		return 2
+	case bits < 33.2:
+		return 3
+	}
+
+	return 4
+}
+
+func passwordEntropy(password string) float64 {
+	lowerPassword := []rune(strings.ToLower(password))
+	characterBits := math.Log2(float64(charsetSize(password)))
+	dictionaryBits := math.Log2(float64(len(commonPasswords)))
+
+	bits := 0.0
+	for i := 0; i < len(lowerPassword); {
+		if word := commonWordAt(lowerPassword, i); word != "" {
+			bits += dictionaryBits
+			i += len([]rune(word))
+			continue
+		}
+
+		if i > 0 && predictable(lowerPassword[i-1], lowerPassword[i]) {
+			bits++
+		} else {
+			bits += characterBits
+		}
+		i++
+	}
+
+	return bits
+}
+
+// charsetSize is how many characters an attacker would try for each position of the password
+func charsetSize(password string) (size int) {
+	var lower, upper, digit, symbol, other bool
+
+	for _, character := range password {
+		switch {
+		case character >= 'a' && character <= 'z':
+			lower = true
+		case character >= 'A' && character <= 'Z':
+			upper = true
+		case unicode.IsDigit(character):
+			digit = true
+		case character < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			size += class.size
+		}
+	}
+
+	return max(size, 1)
+}
+
+func commonWordAt(password []rune, position int) string {
+	for _, word := range commonPasswords {
+		if strings.HasPrefix(string(password[position:]), word) {
+			return word
+		}
+	}
+
+	return ""
+}
+
+// predictable reports if a character repeats the previous one, follows it on a sequence or on a keyboard row
+func predictable(previous, current rune) bool {
+	if current == previous || current == previous+1 || current == previous-1 {
+		return true
+	}
+
+	for _, row := range keyboardRows {
+		index := strings.IndexRune(row, previous)
+		if index < 0 {
+			continue
+		}
+
+		if (index+1 < len(row) && rune(row[index+1]) == current) || (index > 0 && rune(row[index-1]) == current) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// personalInformationParts splits an information like "John Smith" or "john.smith@gmail.com" on the pieces
+// long enough to be meaningful inside a password
+func personalInformationParts(information string) (parts []string) {
+	information = strings.ToLower(information)
+	if localPart, _, found := strings.Cut(information, "@"); found {
+		information = localPart
+	}
+
+	pieces := strings.FieldsFunc(information, func(character rune) bool {
+		return !unicode.IsLetter(character) && !unicode.IsDigit(character)
+	})
+
+	for _, piece := range pieces {
+		if len([]rune(piece)) >= 3 {
+			parts = append(parts, piece)
+		}
+	}
+
+	return
+}
+
+// LoadBreachedPasswords prepares the breached passwords list. The path can be a directory of files named by
+// the first 5 characters of the SHA-1 (like the k-anonymity API of Have I Been Pwned), read on demand, or a single
+// file with one "SHA1:COUNT" per line, loaded on memory
+func LoadBreachedPasswords() error {
+	breachedPasswords = nil
+
+	path := config.BreachedPasswordsPath
+	if path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	breachedPasswords = map[string]map[string]struct{}{}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if len(hash) != sha1.Size*2 {
+			continue
+		}
+
+		hash = strings.ToUpper(hash)
+		prefix, suffix := hash[:5], hash[5:]
+		if breachedPasswords[prefix] == nil {
+			breachedPasswords[prefix] = map[string]struct{}{}
+		}
+		breachedPasswords[prefix][suffix] = struct{}{}
+	}
+
+	return scanner.Err()
+}
+
+// IsBreachedPassword reports if the password is on the breached passwords list
+func IsBreachedPassword(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	if breachedPasswords != nil {
+		_, breached := breachedPasswords[prefix][suffix]
+		return breached, nil
+	}
+
+	if config.BreachedPasswordsPath == "" {
+		return false, nil
+	}
+
+	file, err := os.Open(filepath.Join(config.BreachedPasswordsPath, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(lineSuffix, suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
@@ -1,13 +1,41 @@
 package security
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"sync"
+)
 
-// Hash changes a string to a encrypted Hash
+var dummyPasswordHash struct {
+	once sync.Once
+	hash []byte
+}
+
+// Hash changes a string to a encrypted Hash, using the configured algorithm
 func Hash(password string) ([]byte, error) {
-	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return CurrentHasher().Hash(password)
 }
 
-// ValidatePassword compare a password string to a encrypted hash of a password
+// ValidatePassword compare a password string to a encrypted hash of a password, made by any supported algorithm
 func ValidatePassword(passwordHash, passwordString string) error {
-	return bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(passwordString))
+	hasher, err := hasherFor(passwordHash)
+	if err != nil {
+		return err
+	}
+
+	return hasher.Validate(passwordHash, passwordString)
+}
+
+// NeedsRehash reports if a hash was made by another algorithm or other parameters than the configured ones
+func NeedsRehash(passwordHash string) bool {
+	hasher := CurrentHasher()
+	return !hasher.Recognizes(passwordHash) || hasher.Outdated(passwordHash)
+}
+
+// SimulatePasswordValidation takes as long as ValidatePassword, so a request for a missing user
+// can't be told apart from a wrong password by its response time
+func SimulatePasswordValidation(passwordString string) {
+	dummyPasswordHash.once.Do(func() {
+		dummyPasswordHash.hash, _ = Hash("dummy password")
+	})
+
+	ValidatePassword(string(dummyPasswordHash.hash), passwordString)
 }
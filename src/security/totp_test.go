@@ -0,0 +1,83 @@
+package security
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 secret of the test vectors of RFC 6238 ("12345678901234567890"), in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP(t *testing.T) {
+	cases := []struct {
+		name      string
+		secret    string
+		code      string
+		now       int64
+		wantStep  uint64
+		wantValid bool
+	}{
+		// The RFC gives 8 digits, the last 6 are the code
+		{"RFC 6238 at 59", rfc6238Secret, "287082", 59, 1, true},
+		{"RFC 6238 at 1111111109", rfc6238Secret, "081804", 1111111109, 37037036, true},
+		{"RFC 6238 at 1111111111", rfc6238Secret, "050471", 1111111111, 37037037, true},
+		{"RFC 6238 at 1234567890", rfc6238Secret, "005924", 1234567890, 41152263, true},
+		{"RFC 6238 at 2000000000", rfc6238Secret, "279037", 2000000000, 66666666, true},
+		{"a lowercase secret", strings.ToLower(rfc6238Secret), "005924", 1234567890, 41152263, true},
+		{"one step of drift behind", rfc6238Secret, "005924", 1234567890 + 30, 41152263, true},
+		{"one step of drift ahead", rfc6238Secret, "005924", 1234567890 - 30, 41152263, true},
+		{"two steps of drift", rfc6238Secret, "005924", 1234567890 + 60, 0, false},
+		{"a wrong code", rfc6238Secret, "005925", 1234567890, 0, false},
+		{"the 8 digits", rfc6238Secret, "89005924", 1234567890, 0, false},
+		{"an invalid secret", "not base32!", "005924", 1234567890, 0, false},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			step, valid := ValidateTOTP(testCase.secret, testCase.code, time.Unix(testCase.now, 0))
+			if step != testCase.wantStep || valid != testCase.wantValid {
+				t.Errorf("got step %d and valid %v, want %d and %v", step, valid, testCase.wantStep, testCase.wantValid)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Errorf("got a secret of %d bytes: %v", len(key), err)
+	}
+
+	step, valid := ValidateTOTP(secret, totpCode(key, 1000), time.Unix(1000*totpPeriod, 0))
+	if !valid || step != 1000 {
+		t.Errorf("the code of the secret got step %d and valid %v", step, valid)
+	}
+}
+
+func TestGenerateRecoveryCode(t *testing.T) {
+	format := regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`)
+	codes := map[string]bool{}
+
+	for i := 0; i < 100; i++ {
+		code, err := GenerateRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !format.MatchString(code) {
+			t.Errorf("got %q", code)
+		}
+
+		if codes[code] {
+			t.Errorf("got %q twice", code)
+		}
+		codes[code] = true
+	}
+}
@@ -1,9 +1,14 @@
 package templates
 
 import (
+	"context"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 )
 
 // JSON return a http response in JSON for a request
@@ -18,11 +23,37 @@ func JSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	}
 }
 
-// Error return a error formated in JSON
+// Error return a error formated in JSON. A database that didn't answer in time is answered with 504, and one
+// that can't be reached with 503, whatever the status given, so clients know they can try again
 func Error(w http.ResponseWriter, statusCode int, err error) {
+	if databaseStatusCode, databaseErr := databaseError(err); databaseErr != nil {
+		log.Printf("database error: %v", err)
+		statusCode, err = databaseStatusCode, databaseErr
+	}
+
 	JSON(w, statusCode, struct {
 		Error string `json:"error"`
 	}{
 		Error: err.Error(),
 	})
 }
+
+// databaseError gives the status and the error shown to the client when the database couldn't do its work.
+// Errors of requests to other services, like the OpenID Connect providers, are kept as they are
+func databaseError(err error) (int, error) {
+	var urlErr *url.Error
+	var netErr *net.OpError
+
+	switch {
+	case errors.As(err, &urlErr):
+		return 0, nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, errors.New("the database didn't answer in time")
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, errors.New("the request was canceled")
+	case errors.Is(err, driver.ErrBadConn), errors.As(err, &netErr):
+		return http.StatusServiceUnavailable, errors.New("the database is unavailable")
+	}
+
+	return 0, nil
+}
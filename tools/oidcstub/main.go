@@ -0,0 +1,199 @@
+// Command oidcstub is a minimal OpenID Connect provider to try the OIDC login locally. It signs in
+// everyone as the user given on its flags, without asking for a password. Never expose it.
+//
+//	go run ./tools/oidcstub -addr :9999 -email user@example.com
+//
+// And on the API .env:
+//
+//	OIDC_DISCOVERY_URLS=stub=http://localhost:9999/.well-known/openid-configuration
+//	OIDC_CLIENT_IDS=stub=socialmedia
+//	OIDC_CLIENT_SECRETS=stub=secret
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+var (
+	addr          = flag.String("addr", ":9999", "address to listen on")
+	issuer        = flag.String("issuer", "http://localhost:9999", "issuer, the public address of the stub")
+	clientID      = flag.String("client-id", "socialmedia", "client ID accepted")
+	clientSecret  = flag.String("client-secret", "secret", "client secret accepted")
+	subject       = flag.String("sub", "stub-user-1", "subject of the signed in user")
+	email         = flag.String("email", "user@example.com", "email of the signed in user")
+	emailVerified = flag.Bool("email-verified", true, "whether the email is verified")
+	name          = flag.String("name", "Stub User", "name of the signed in user")
+	username      = flag.String("username", "stubuser", "preferred username of the signed in user")
+
+	key            *rsa.PrivateKey
+	mutex          sync.Mutex
+	authorizations = map[string]authorization{}
+)
+
+func main() {
+	flag.Parse()
+
+	var err error
+	if key, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		log.Fatal(err)
+	}
+
+	http.HandleFunc("/.well-known/openid-configuration", discovery)
+	http.HandleFunc("/jwks", jwks)
+	http.HandleFunc("/authorize", authorize)
+	http.HandleFunc("/token", token)
+
+	log.Printf("OpenID Connect stub listening at %s, issuer %s", *addr, *issuer)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+func discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                *issuer,
+		"authorization_endpoint":                *issuer + "/authorize",
+		"token_endpoint":                        *issuer + "/token",
+		"jwks_uri":                              *issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "stub",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize signs the user in right away, sending the code back to the client
+func authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != *clientID || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "unknown client or missing PKCE", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	mutex.Lock()
+	authorizations[code] = authorization{
+		clientID:      query.Get("client_id"),
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+	mutex.Unlock()
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	values := redirectURI.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirectURI.RawQuery = values.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id != *clientID || secret != *clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	mutex.Lock()
+	grant, found := authorizations[r.PostForm.Get("code")]
+	delete(authorizations, r.PostForm.Get("code"))
+	mutex.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !found || grant.redirectURI != r.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != grant.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                *issuer,
+		"sub":                *subject,
+		"aud":                grant.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              grant.nonce,
+		"email":              *email,
+		"email_verified":     *emailVerified,
+		"name":               *name,
+		"preferred_username": *username,
+	})
+	idToken.Header["kid"] = "stub"
+
+	signedIDToken, err := idToken.SignedString(key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signedIDToken,
+	})
+}
+
+func randomString() string {
+	randomBytes := make([]byte, 24)
+	if _, err := rand.Read(randomBytes); err != nil {
+		log.Fatal(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(randomBytes)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Print(err)
+	}
+}